@@ -0,0 +1,145 @@
+package main
+
+import "math"
+
+// --- Runner actions ---
+
+// Obstacle kinds, each cleared a different way
+const (
+	kindBarrier = iota // full height, change lanes
+	kindLow            // low wall, jump it
+	kindHigh           // high bar, slide under it
+)
+
+// What the autopilot does in a tick
+const (
+	pilotNone  = iota // carry on as it is
+	pilotMove         // change lanes
+	pilotJump         // jump a low wall
+	pilotSlide        // slide under a high bar
+)
+
+// pilotNames are the autopilot's actions as they're logged.
+var pilotNames = [...]string{pilotNone: "none", pilotMove: "move", pilotJump: "jump", pilotSlide: "slide"}
+
+const actionSecs = 0.6 // how long a slide lasts, and a jump unless -jump-secs says otherwise
+
+func (g *game) airborne() bool { return g.jumpT > 0 }
+func (g *game) sliding() bool  { return g.slideT > 0 }
+
+func (g *game) jump() {
+	if g.airborne() || g.sliding() {
+		return
+	}
+	g.jumpT = g.cfg.jumpSecs
+}
+
+// jumpLift is how many rows off the ground the runner is, rising and falling
+// along an arc that peaks at the configured jump height halfway through.
+func (g *game) jumpLift() int {
+	if !g.airborne() {
+		return 0
+	}
+	return int(math.Sin((1-g.jumpT/g.cfg.jumpSecs)*math.Pi)*float64(g.cfg.jumpHeight) + 0.5)
+}
+
+func (g *game) slide() {
+	if g.airborne() || g.sliding() {
+		return
+	}
+	g.slideT = actionSecs
+}
+
+// steer starts a lane change toward lane.
+func (g *game) steer(lane int) {
+	if lane == g.targetLane {
+		return
+	}
+	g.targetLane = lane
+	g.steeredAt = g.elapsed
+	g.laneFrom, g.laneT = g.laneX, 0
+}
+
+// dodgedLate reports whether the runner had already started steering out of
+// lane when something there hit them, recently enough that the leniency
+// window lets them off.
+func (g *game) dodgedLate(lane int) bool {
+	return g.targetLane != lane && g.elapsed-g.steeredAt < g.cfg.leniency
+}
+
+// clears reports whether the runner gets past an obstacle of the given kind
+// as things stand right now.
+func (g *game) clears(kind int) bool {
+	switch kind {
+	case kindLow:
+		return g.airborne()
+	case kindHigh:
+		return g.sliding()
+	}
+	return false
+}
+
+// planAct is whether to jump or slide for a low wall or high bar coming down
+// the lane we're headed for, timed so the move peaks as it arrives, or to jump
+// a gap.
+func (g *game) planAct() int {
+	if g.airborne() || g.sliding() {
+		return pilotNone
+	}
+	if g.gapJump() {
+		return pilotJump
+	}
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		if !obs.active || obs.lane != g.targetLane || obs.z < runnerZ {
+			continue
+		}
+		switch {
+		case obs.kind == kindLow && obs.z-runnerZ <= g.speed*g.cfg.jumpSecs/2:
+			return pilotJump
+		case obs.kind == kindHigh && obs.z-runnerZ <= g.speed*actionSecs/2:
+			return pilotSlide
+		}
+	}
+	return pilotNone
+}
+
+// key handles a keypress from the player. Steering keys only do anything in
+// manual mode, the autopilot has the wheel otherwise.
+func (g *game) key(k keyPress) {
+	if g.cfg.tune && g.tuneKey(k) {
+		return
+	}
+	if k == '?' {
+		g.hintsOn = !g.hintsOn
+		g.hintsPinned = g.hintsOn
+		return
+	}
+	// Any other key skips the countdown
+	if g.countdown > 0 {
+		g.countdown = 0
+		return
+	}
+	if k == 'p' {
+		g.paused = !g.paused
+		return
+	}
+	if !g.cfg.manual || g.frozen() {
+		return
+	}
+
+	switch k {
+	case 'a', 'h', keyLeft:
+		if g.targetLane > 0 {
+			g.steer(g.targetLane - 1)
+		}
+	case 'd', 'l', keyRight:
+		if g.targetLane < numLanes-1 {
+			g.steer(g.targetLane + 1)
+		}
+	case 'w', 'k', ' ', keyUp:
+		g.jump()
+	case 's', 'j', keyDown:
+		g.slide()
+	}
+}
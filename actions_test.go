@@ -0,0 +1,308 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestPlanDodgeActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     int
+		lead     float64 // how far ahead of the runner it is, in seconds at the current speed
+		airborne bool
+		action   int
+	}{
+		{"low wall close", kindLow, 0.2, false, pilotJump},
+		{"low wall far", kindLow, 1, false, pilotNone},
+		{"high bar close", kindHigh, 0.2, false, pilotSlide},
+		{"high bar far", kindHigh, 1, false, pilotNone},
+		{"barrier", kindBarrier, 0.5, false, pilotMove},
+		{"low wall already jumping", kindLow, 0.2, true, pilotNone},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual")
+		clearTrack(g)
+		g.obstacles[0] = obstacle{lane: 1, kind: tt.kind, z: runnerZ + tt.lead*g.speed, active: true}
+		if tt.airborne {
+			g.jumpT = g.cfg.jumpSecs
+		}
+		action, lane, _ := g.planDodge()
+		if action != tt.action {
+			t.Errorf("%s: action %s, want %s", tt.name, pilotNames[action], pilotNames[tt.action])
+		}
+		if action == pilotMove && (lane == 1 || lane < 0) {
+			t.Errorf("%s: moved to lane %d, out of the way is 0 or 2", tt.name, lane)
+		}
+	}
+}
+
+func TestClears(t *testing.T) {
+	tests := []struct {
+		kind              int
+		airborne, sliding bool
+		want              bool
+	}{
+		{kindLow, true, false, true},
+		{kindLow, false, true, false},
+		{kindHigh, false, true, true},
+		{kindHigh, true, false, false},
+		{kindBarrier, true, false, false},
+		{kindBarrier, false, true, false},
+		{kindBarrier, false, false, false},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24)
+		if tt.airborne {
+			g.jumpT = 0.1
+		}
+		if tt.sliding {
+			g.slideT = 0.1
+		}
+		if got := g.clears(tt.kind); got != tt.want {
+			t.Errorf("kind %d, airborne %v, sliding %v: clears = %v, want %v", tt.kind, tt.airborne, tt.sliding, got, tt.want)
+		}
+	}
+}
+
+func TestAutopilotClearsEachKind(t *testing.T) {
+	for _, kind := range []int{kindBarrier, kindLow, kindHigh} {
+		g := testGame(t, 80, 24, "-mode", "practice")
+		clearTrack(g)
+		buf := logEvents(g)
+		g.obstacles[0] = obstacle{lane: g.runnerLane, kind: kind, z: 8, active: true}
+		for range 40 {
+			g.update(0.05)
+		}
+		if crashes := eventLines(buf, "crash"); len(crashes) > 0 {
+			t.Errorf("kind %d: autopilot crashed: %v", kind, crashes)
+		}
+	}
+}
+
+func TestPilotLog(t *testing.T) {
+	tests := []struct {
+		name string
+		kind int // -1 for a gap
+		lane int // lane the obstacle's in, the runner's in 1
+		want []string
+	}{
+		{"barrier", kindBarrier, 1, []string{"msg=pilot action=move lane=0 gap=false"}},
+		{"low wall", kindLow, 1, []string{"msg=pilot action=jump lane=1 gap=false"}},
+		{"high bar", kindHigh, 1, []string{"msg=pilot action=slide lane=1 gap=false"}},
+		{"gap", -1, 1, []string{"msg=pilot action=jump lane=1 gap=true"}},
+		{"another lane", kindBarrier, 2, nil},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-mode", "practice")
+		clearTrack(g)
+		g.spawnTimer, g.coinTimer = -100, -100 // nothing new to act on
+		buf := logEvents(g, "t")
+		if tt.kind < 0 {
+			g.gap = gap{start: 8, end: 8 + g.speed*g.cfg.jumpSecs*gapShare, active: true}
+		} else {
+			g.obstacles[0] = obstacle{lane: tt.lane, kind: tt.kind, z: 8, active: true}
+		}
+		for range 40 {
+			g.update(0.05)
+		}
+		if got := eventLines(buf, "pilot"); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: pilot log %q, want %q", tt.name, got, tt.want)
+		}
+		if crashes := eventLines(buf, "crash", "fall"); len(crashes) > 0 {
+			t.Errorf("%s: crashed: %v", tt.name, crashes)
+		}
+	}
+}
+
+func TestDodgedLate(t *testing.T) {
+	const leniency = 0.125 // exact in binary, so the edge really is the edge
+	tests := []struct {
+		name  string
+		since float64 // seconds since steering out of lane 1
+		lane  int     // lane steered to
+		want  bool
+	}{
+		{"just steered", 0, 0, true},
+		{"inside the window", leniency - 0.001, 0, true},
+		{"at the edge", leniency, 0, false},
+		{"past the window", leniency + 0.001, 2, false},
+		{"steered into it", 0, 1, false},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual", "-leniency", "0.125")
+		g.elapsed = 8
+		g.steer(tt.lane)
+		g.elapsed += tt.since
+		if got := g.dodgedLate(1); got != tt.want {
+			t.Errorf("%s: dodgedLate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLeniencyWindow(t *testing.T) {
+	const dt = 0.001
+	tests := []struct {
+		name    string
+		args    []string
+		since   float64 // seconds before the hit that the runner steered away
+		crashed bool
+	}{
+		{"well inside", []string{"-leniency", "0.1"}, 0.05, false},
+		{"just inside", []string{"-leniency", "0.1"}, 0.1 - 3*dt, false},
+		{"just outside", []string{"-leniency", "0.1"}, 0.1 + dt, true},
+		{"no leniency", []string{"-leniency", "0"}, 0, true},
+		{"easy", []string{"-difficulty", "easy"}, 0.1, false},
+		{"hard", []string{"-difficulty", "hard"}, 0.01, true},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, append([]string{"-manual", "-mode", "practice"}, tt.args...)...)
+		clearTrack(g)
+		buf := logEvents(g)
+		g.elapsed = 5
+		g.steer(0)
+		g.steeredAt -= tt.since
+		// Close enough to reach the runner this tick, while the runner's
+		// still all but in lane 1
+		g.obstacles[0] = obstacle{lane: 1, kind: kindBarrier, z: runnerZ + g.speed*dt/2, active: true}
+		g.update(dt)
+		crashed := len(eventLines(buf, "crash")) > 0
+		if crashed != tt.crashed {
+			t.Errorf("%s: crashed = %v, want %v", tt.name, crashed, tt.crashed)
+		}
+		if late := len(eventLines(buf, "dodge.late")) > 0; late == crashed {
+			t.Errorf("%s: crashed %v and let off %v", tt.name, crashed, late)
+		}
+	}
+}
+
+func TestJumpLift(t *testing.T) {
+	g := testGame(t, 80, 24, "-manual", "-jump-secs", "1", "-jump-height", "3")
+	g.jump()
+	var lifts []int
+	for g.airborne() {
+		lifts = append(lifts, g.jumpLift())
+		g.jumpT -= 0.05
+	}
+	if len(lifts) != 20 {
+		t.Fatalf("a 1s jump lasted %d ticks of 0.05s", len(lifts))
+	}
+	peak := 0
+	for i, l := range lifts {
+		if l > lifts[peak] {
+			peak = i
+		}
+	}
+	if lifts[0] != 0 || lifts[peak] != 3 || lifts[len(lifts)/2] != 3 {
+		t.Errorf("jump went %v, want from the ground to 3 rows halfway", lifts)
+	}
+	for i := 1; i < len(lifts); i++ {
+		if i <= peak && lifts[i] < lifts[i-1] || i > peak && lifts[i] > lifts[i-1] {
+			t.Errorf("jump went %v, want up then down", lifts)
+			break
+		}
+	}
+}
+
+func TestAutopilotTunedJumps(t *testing.T) {
+	var p pattern
+	for _, q := range patterns {
+		if q.name == "jump-then-slide" {
+			p = q
+		}
+	}
+	for _, secs := range []string{"0.2", "0.6", "1.2"} {
+		for _, speed := range []int{5, 20, 40} {
+			sp := strconv.Itoa(speed)
+			g := testGame(t, 80, 24, "-mode", "practice", "-jump-secs", secs, "-start-speed", sp, "-max-speed", sp)
+			clearTrack(g)
+			buf := logEvents(g)
+			for i, s := range p.steps {
+				g.obstacles[i] = obstacle{lane: s.lane, kind: s.kind, z: g.cfg.spawnZ + s.at*g.speed, active: true}
+			}
+			for range 10 * 20 {
+				g.spawnTimer, g.coinTimer = -100, -100 // just the pattern
+				g.update(0.05)
+			}
+			if crashes := eventLines(buf, "crash"); len(crashes) > 0 {
+				t.Errorf("%ss jumps at speed %d: crashed %v", secs, speed, crashes)
+			}
+		}
+	}
+}
+
+func TestJumpTiming(t *testing.T) {
+	const dt = 0.05
+	tests := []struct {
+		name    string
+		kind    int
+		jumpT   float64 // time left in the air at the start of the tick
+		crashed bool
+	}{
+		{"landed just in time", kindLow, dt / 2, false},
+		{"in the air all tick", kindLow, 1, false},
+		{"landed the tick before", kindLow, 0, true},
+		{"jump over a tall obstacle fails", kindBarrier, 1, true},
+		{"jump into a high bar fails", kindHigh, 1, true},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual", "-mode", "practice")
+		clearTrack(g)
+		buf := logEvents(g)
+		g.jumpT = tt.jumpT
+		g.obstacles[0] = obstacle{lane: 1, kind: tt.kind, z: runnerZ + g.speed*dt/2, active: true}
+		g.update(dt)
+		if crashed := len(eventLines(buf, "crash")) > 0; crashed != tt.crashed {
+			t.Errorf("%s: crashed = %v, want %v", tt.name, crashed, tt.crashed)
+		}
+	}
+}
+
+func TestDoubleHit(t *testing.T) {
+	// Two trains reach the runner in the same tick, the way a long step can
+	// bring them. Each costs a life, but once the run's over the second
+	// doesn't take lives below nothing
+	tests := []struct {
+		lives   string
+		crashes int
+		left    int
+		over    bool
+	}{
+		{"1", 1, 0, true},
+		{"2", 2, 0, true},
+		{"3", 2, 1, false},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual", "-mode", "forgiving", "-lives", tt.lives)
+		clearTrack(g)
+		buf := logEvents(g)
+		const dt = 0.1
+		g.obstacles[0] = obstacle{lane: 1, kind: kindBarrier, z: runnerZ + 0.01, active: true}
+		g.obstacles[1] = obstacle{lane: 1, kind: kindBarrier, z: runnerZ + g.speed*dt/2, active: true}
+		g.update(dt)
+		if n := len(eventLines(buf, "crash")); n != tt.crashes {
+			t.Errorf("%s lives: %d crashes, want %d", tt.lives, n, tt.crashes)
+		}
+		if g.lives != tt.left || g.over != tt.over {
+			t.Errorf("%s lives: left with %d, over %v, want %d, over %v", tt.lives, g.lives, g.over, tt.left, tt.over)
+		}
+	}
+}
+
+func TestPracticeScoreStopsAtZero(t *testing.T) {
+	tests := []struct{ score, want int }{
+		{2000, 2000 - practiceHitCost},
+		{practiceHitCost, 0},
+		{100, 0},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-mode", "practice")
+		g.score = tt.score
+		g.crash()
+		if g.score != tt.want || g.over {
+			t.Errorf("hit at %d: score %d, over %v, want %d and running", tt.score, g.score, g.over, tt.want)
+		}
+	}
+}
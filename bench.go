@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	benchWidth  = 80
+	benchHeight = 24
+)
+
+// runBench simulates and renders frames as fast as it can without touching
+// the terminal, then reports how long it took to info and where the run got
+// to as results on out. It's 80x24 unless -size says otherwise.
+func runBench(cfg config, out, info io.Writer) error {
+	w, h := benchWidth, benchHeight
+	if cfg.width > 0 {
+		w, h = cfg.width, cfg.height
+	}
+	g := newGame(w, h, cfg)
+	dt := 1.0 / targetFPS
+
+	start := time.Now()
+	sent := 0
+	for i := 0; i < cfg.bench; i++ {
+		g.update(dt)
+		sent += len(g.render())
+	}
+	took := time.Since(start)
+
+	perFrame := took / time.Duration(cfg.bench)
+	fmt.Fprintf(info, "%d frames at %dx%d in %v (%v/frame, %.0f fps, %d bytes/frame)\n",
+		cfg.bench, w, h, took.Round(time.Millisecond), perFrame, float64(cfg.bench)/took.Seconds(), sent/cfg.bench)
+	return writeResults(out, g)
+}
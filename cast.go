@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// --- asciinema casts ---
+
+// castWriter passes output through to the terminal and records every write as
+// an asciinema v2 event. Each event goes straight to the file, so a run that
+// crashes still leaves a cast that plays up to that point.
+type castWriter struct {
+	out   io.Writer
+	f     *os.File
+	start time.Time
+	err   error // first write to the cast that failed, recording stops there
+}
+
+// openCast creates the cast at path and writes its header for a w by h
+// screen. Output written to the returned writer goes on to out as well.
+func openCast(path string, out io.Writer, w, h int) (*castWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &castWriter{out: out, f: f, start: time.Now()}
+	header, _ := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     w,
+		"height":    h,
+		"timestamp": c.start.Unix(),
+		"env":       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if _, err := fmt.Fprintf(f, "%s\n", header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *castWriter) Write(p []byte) (int, error) {
+	if c.err == nil && len(p) > 0 {
+		data, _ := json.Marshal(string(p))
+		_, c.err = fmt.Fprintf(c.f, "[%.6f, \"o\", %s]\n", time.Since(c.start).Seconds(), data)
+	}
+	return c.out.Write(p)
+}
+
+// Close finishes the cast, reporting the first write that didn't make it.
+func (c *castWriter) Close() error {
+	err := c.f.Close()
+	if c.err != nil {
+		return c.err
+	}
+	return err
+}
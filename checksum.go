@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+)
+
+// --- Checksums ---
+//
+// -checksum plays a seeded run the way -headless does, rendering every frame
+// too, and prints two hashes of it for CI to pin down:
+//
+//   - state hashes the run as it ends: every field a save holds (see
+//     savedGame) apart from the save's version and the flags, so score,
+//     coins, lives, the runner, every timer, both object pools whole, the
+//     bonus zone, the lane closure, the gap and how many random draws were
+//     made
+//   - frames is a running hash of the whole screen after every frame, so
+//     it catches drawing changes the state hash can't see
+//
+// Any change to how the sim plays or draws moves one or both, which is the
+// point. When a change is meant to, update the expected hashes with it.
+
+// checksumVersion goes up whenever what feeds the hashes changes, like a
+// field added to savedGame. It says a new hash is expected, not a bug.
+const checksumVersion = 5
+
+type checksums struct {
+	Version int    `json:"version"`
+	Seed    int64  `json:"seed"`
+	Ticks   int    `json:"ticks"`
+	State   string `json:"state"`
+	Frames  string `json:"frames"`
+}
+
+// stateHash is the hash of g as it stands.
+func (g *game) stateHash() (string, error) {
+	sv := g.saved()
+	sv.Version, sv.Args = 0, nil
+	data, err := json.Marshal(sv)
+	if err != nil {
+		return "", err
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf("%016x", h.Sum64()), nil
+}
+
+// runChecksum plays a whole seeded run on autopilot at a fixed step and
+// screen size, hashing every frame, and prints the hashes.
+func runChecksum(cfg config, out io.Writer, logger *slog.Logger) error {
+	w, h := benchWidth, benchHeight
+	if cfg.width > 0 {
+		w, h = cfg.width, cfg.height
+	}
+	g := newGame(w, h, cfg)
+	g.log = logger
+	frames := fnv.New64a()
+	dt := 1.0 / targetFPS
+	ticks := 0
+	for !g.ended() && g.elapsed < headlessMaxSecs {
+		g.update(dt)
+		g.render()
+		frames.Write(g.screen)
+		ticks++
+	}
+
+	state, err := g.stateHash()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(out).Encode(checksums{
+		Version: checksumVersion,
+		Seed:    g.seed,
+		Ticks:   ticks,
+		State:   state,
+		Frames:  fmt.Sprintf("%016x", frames.Sum64()),
+	})
+}
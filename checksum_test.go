@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// checksum runs -checksum with args and returns what it printed.
+func checksum(t *testing.T, args ...string) checksums {
+	t.Helper()
+	var out bytes.Buffer
+	cfg := testConfig(t, append([]string{"-checksum", "-mode", "hardcore"}, args...)...)
+	if err := runChecksum(cfg, &out, slog.New(slog.DiscardHandler)); err != nil {
+		t.Fatal(err)
+	}
+	var sums checksums
+	if err := json.Unmarshal(out.Bytes(), &sums); err != nil {
+		t.Fatalf("%v in %q", err, out.String())
+	}
+	return sums
+}
+
+func TestChecksum(t *testing.T) {
+	a := checksum(t, "-seed", "3")
+	if b := checksum(t, "-seed", "3"); a != b {
+		t.Errorf("the same run hashed differently: %+v and %+v", a, b)
+	}
+	if a.Seed != 3 || a.Ticks == 0 || a.Version != checksumVersion {
+		t.Errorf("got %+v", a)
+	}
+
+	// Another course moves both hashes, another view only the frames
+	if b := checksum(t, "-seed", "4"); b.State == a.State || b.Frames == a.Frames {
+		t.Errorf("another seed hashed the same: %+v and %+v", a, b)
+	}
+	if b := checksum(t, "-seed", "3", "-view", "topdown"); b.State != a.State || b.Frames == a.Frames {
+		t.Errorf("topdown: %+v, perspective: %+v, want only the frames to differ", b, a)
+	}
+}
+
+func TestChecksumNeedsSeed(t *testing.T) {
+	for _, args := range [][]string{
+		{"-checksum"},
+		{"-checksum", "-seed", "1", "-headless"},
+		{"-checksum", "-seed", "1", "-manual"},
+	} {
+		if _, err := parseConfig(args); err == nil {
+			t.Errorf("%q parsed", args)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import "math"
+
+// --- Lane closures ---
+//
+// Every so often a stretch of an outside lane goes up in flames, and the
+// runner has to keep out of it until it's gone by. Only one lane burns at a
+// time and never the middle one, so the two open lanes are side by side and
+// getting from one to the other never means running through the fire.
+
+const (
+	closureLength = 30.0 // track length a closure burns along
+	closureGap    = 3.0  // barriers closer than this in both open lanes leave no way through
+)
+
+// closure is a stretch of a lane that's on fire. Like a bonus zone, its start
+// and end travel toward the viewer.
+type closure struct {
+	lane       int
+	start, end float64
+	active     bool
+}
+
+// closable reports whether lane can close without boxing the runner in: no
+// barriers side by side in the other two lanes, anywhere ahead.
+func (g *game) closable(lane int) bool {
+	for i := range g.obstacles {
+		a := &g.obstacles[i]
+		if !a.active || a.kind != kindBarrier || a.lane == lane || a.z < runnerZ {
+			continue
+		}
+		for j := range g.obstacles {
+			b := &g.obstacles[j]
+			if b.active && b.kind == kindBarrier && b.lane != lane && b.lane != a.lane && math.Abs(a.z-b.z) < closureGap {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// startClosure sets an outside lane on fire at the horizon, if one can close,
+// and reports whether it did. It only draws a lane from rng when both could.
+func (g *game) startClosure() bool {
+	var lanes []int
+	for _, l := range [...]int{0, numLanes - 1} {
+		if g.closable(l) {
+			lanes = append(lanes, l)
+		}
+	}
+	if len(lanes) == 0 {
+		return false
+	}
+	lane := lanes[0]
+	if len(lanes) > 1 {
+		lane = lanes[g.rng.Intn(len(lanes))]
+	}
+	g.closure = closure{
+		lane:   lane,
+		start:  g.cfg.spawnZ,
+		end:    g.cfg.spawnZ + closureLength,
+		active: true,
+	}
+	g.log.Info("closure", "t", g.elapsed, "lane", lane, "length", closureLength)
+	return true
+}
+
+// updateClosure moves the fire toward the viewer and burns the runner if
+// they're in it. A hit puts the fire out, so it costs one crash, not one a
+// tick. A run an obstacle has just ended is left as it is.
+func (g *game) updateClosure(dt float64, runnerAt int) {
+	c := &g.closure
+	if !c.active || g.over {
+		return
+	}
+	c.start -= g.speed * dt
+	c.end -= g.speed * dt
+	if c.end < -1 {
+		c.active = false
+		return
+	}
+	if runnerAt != c.lane || c.start > runnerZ || c.end <= runnerZ || g.dodgedLate(c.lane) {
+		return
+	}
+	c.active = false
+	g.crash()
+}
+
+// closureAhead reports whether the fire's close enough for the autopilot to
+// steer clear of.
+func (g *game) closureAhead() bool {
+	return g.closure.active && g.closure.start < g.cfg.lookahead && g.closure.end > 0
+}
+
+// fireGlyph is the flame at column x of a row. Flames flicker, unless motion's
+// turned down.
+func (g *game) fireGlyph(x, row int) byte {
+	if !g.cfg.reducedMotion && (x+row+int(g.elapsed*8))%3 == 0 {
+		return '*'
+	}
+	return '%'
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestClosable(t *testing.T) {
+	g := testGame(t, 80, 24)
+	clearTrack(g)
+	// Barriers side by side in the middle and right lanes leave the left
+	// one as the only way through
+	g.obstacles[0] = obstacle{lane: 1, kind: kindBarrier, z: 12, active: true}
+	g.obstacles[1] = obstacle{lane: 2, kind: kindBarrier, z: 13, active: true}
+	if g.closable(0) {
+		t.Error("left lane closed with barriers side by side in the other two")
+	}
+	if !g.closable(2) {
+		t.Error("right lane couldn't close with the left one open")
+	}
+
+	// Far enough apart to weave between, or something to jump, doesn't box
+	// the runner in
+	g.obstacles[1].z = 12 + closureGap
+	if !g.closable(0) {
+		t.Error("left lane couldn't close with the barriers a gap apart")
+	}
+	g.obstacles[1] = obstacle{lane: 2, kind: kindLow, z: 12, active: true}
+	if !g.closable(0) {
+		t.Error("left lane couldn't close with a low obstacle beside the barrier")
+	}
+}
+
+func TestClosureBurns(t *testing.T) {
+	g := testGame(t, 80, 24, "-manual", "-mode", "practice", "-closure-every", "0")
+	clearTrack(g)
+	buf := logEvents(g)
+	g.runnerLane, g.targetLane, g.laneX = 0, 0, 0
+	g.closure = closure{lane: 0, start: runnerZ + 2, end: runnerZ + 2 + closureLength, active: true}
+	g.jump() // jumping doesn't help
+	for range 60 {
+		g.update(0.05)
+	}
+	if crashes := eventLines(buf, "crash"); len(crashes) != 1 {
+		t.Errorf("running through the fire crashed %d times, want once: %v", len(crashes), crashes)
+	}
+	if g.closure.active {
+		t.Error("the hit didn't put the fire out")
+	}
+}
+
+func TestAutopilotKeepsOutOfFire(t *testing.T) {
+	for seed := range 10 {
+		g := testGame(t, 80, 24, "-seed", strconv.Itoa(seed), "-mode", "practice", "-closure-every", "4")
+		buf := logEvents(g)
+		for range 60 * 20 {
+			was := g.closure.active
+			g.update(0.05)
+			// A fire that goes out before it's gone past was run into
+			if was && !g.closure.active && g.closure.end >= -1 {
+				t.Errorf("seed %d: autopilot ran into the fire in lane %d at %.2fs", seed, g.closure.lane, g.elapsed)
+			}
+		}
+		if len(eventLines(buf, "closure")) == 0 {
+			t.Errorf("seed %d: no lane closed in a minute", seed)
+		}
+	}
+}
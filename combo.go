@@ -0,0 +1,45 @@
+package main
+
+// --- Coin combo ---
+//
+// Grabbing coins fills a meter and letting them slip past drains it. A full
+// meter sets off a short super multiplier on coin points, and the meter counts
+// that down instead until it runs out.
+
+const (
+	comboFull       = 50 // meter when full
+	comboFill       = 5  // meter gained per coin grabbed
+	comboDrain      = 2  // meter lost per coin that gets past
+	superSecs       = 8.0
+	superMultiplier = 3
+)
+
+// comboCoin fills the meter for a grabbed coin.
+func (g *game) comboCoin() {
+	if g.superT > 0 {
+		return
+	}
+	g.combo = min(g.combo+comboFill, comboFull)
+	if g.combo == comboFull {
+		g.combo = 0
+		g.superT = superSecs
+		g.log.Info("super", "t", g.elapsed)
+	}
+}
+
+// comboMiss drains the meter for a coin that got past.
+func (g *game) comboMiss() {
+	if g.superT > 0 {
+		return
+	}
+	g.combo = max(g.combo-comboDrain, 0)
+}
+
+// comboLevel is how full the meter looks, 0 to 1. During a super multiplier
+// it's the time left.
+func (g *game) comboLevel() float64 {
+	if g.superT > 0 {
+		return g.superT / superSecs
+	}
+	return float64(g.combo) / comboFull
+}
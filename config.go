@@ -0,0 +1,785 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// --- Config ---
+
+const (
+	maxLives      = 9
+	maxStartSpeed = 100
+	maxLeniency   = 0.5
+	minJumpSecs   = 0.2 // a few frames, any shorter and the jump barely shows
+	maxJumpSecs   = 1.2 // leaves time to land before the bar in the jump-then-slide pattern
+	maxJumpHeight = 4
+
+	defaultDistanceValue = 10 // -distance-value, which seed bests are kept apart by
+
+	// Past these, what's near is a dot on the horizon or what's far is on top of you
+	minViewDistance = 8
+	maxViewDistance = 80
+
+	// A step this long carries an obstacle through the autopilot's whole
+	// lookahead at the usual top speed, so it never gets a look at it
+	maxMaxStep = 0.5
+
+	// Closer than a coin's length and a line runs together into a blob, and
+	// much further apart it stops reading as a line
+	minCoinSpacing = 1.0
+	maxCoinSpacing = 6.0
+
+	// How far -pattern-spacing can squash or stretch a pattern
+	minPatternSpacing = 0.5
+	maxPatternSpacing = 3.0
+)
+
+// What happens on a terminal smaller than -min-size
+const (
+	tooSmallWait  = "wait"  // hold the run until it's resized back up
+	tooSmallClamp = "clamp" // play on at the minimum size and cut off what doesn't fit
+)
+
+// HUD positions
+const (
+	hudTopRight = "top-right"
+	hudTopLeft  = "top-left"
+	hudBottom   = "bottom"
+)
+
+// Score formats
+const (
+	scorePadded  = "padded"  // 0012345, the classic
+	scorePlain   = "plain"   // 12345
+	scoreGrouped = "grouped" // 12,345
+	scoreCompact = "compact" // 12.3k
+)
+
+// Game-over modes
+const (
+	modeHardcore  = "hardcore"  // one hit ends the run
+	modeForgiving = "forgiving" // hits cost a life
+	modePractice  = "practice"  // hits cost points, the run never ends
+)
+
+type config struct {
+	difficulty string
+	baseSpeed  float64 // speed at the start of a run
+	speedRamp  float64 // speed gained per second, or per second's worth of rampBy
+	maxSpeed   float64 // speed cap, 0 means uncapped
+	rampBy     string  // what the speed ramps with, see the ramp constants
+
+	mode       string // what a crash does, see the mode constants
+	lives      int    // lives to start with in forgiving mode
+	startScore int    // score to start the run with
+	handicap   bool   // start speed, score or lives moved off the preset
+	tuned      bool   // the course or how it plays moved off the preset, see parseConfig
+
+	leniency float64 // seconds after starting a lane change that a hit in the old lane is let off
+	maxStep  float64 // longest step the sim takes at once, however long a frame took
+
+	// Balance knobs with no flags of their own, tune builds adjust them live
+	spawnScale float64 // scales the obstacle spawn interval, bigger is sparser
+	tune       bool    // live tuning panel, see tune.go
+
+	// How far down the track things can be seen, come in and get dodged
+	farZ      float64 // the horizon, as far as the track's drawn
+	spawnZ    float64 // where obstacles, coins, zones and closures come in
+	lookahead float64 // how far down the track the autopilot looks for trains
+
+	coinWindow    float64 // how close a coin has to get to be grabbed
+	coinLaneReach int     // lanes either side of the runner that coins count from
+	coinValue     int     // points per coin
+	distanceValue float64 // points per unit of track covered
+
+	coinSpacing    float64 // track between the coins in a line
+	patternSpacing float64 // scales the time between a pattern's steps
+
+	maxObjects int // cap on obstacles and coins on screen at once
+
+	waveAmplitude float64 // how far obstacle density swings, 0 to 1
+	wavePeriod    float64 // seconds for a full calm-to-dense cycle
+
+	levelLength float64 // finish line distance, 0 for an endless run
+
+	rushEvery float64 // seconds between coin rushes, 0 for none
+	rushSecs  float64 // how long a coin rush lasts
+
+	closureEvery float64 // seconds between lane closures, 0 for none
+	gapEvery     float64 // seconds between gaps in the track, 0 for none
+
+	seed   int64 // course seed, only used when seeded
+	seeded bool  // set by -seed or -daily
+	daily  bool  // seed derived from today's date
+
+	manual    bool // steer yourself instead of the autopilot
+	challenge bool // start the course over straight after losing, counting the tries
+	skipIntro bool // straight into the run, no title or countdown
+	learn     bool // show obstacle timings and the safe lane, and don't rank the run
+
+	quitKeys string // keys that end the game
+	ctrlC    bool   // ctrl-c ends the game too
+
+	demo     bool // play without reading input, no terminal needed on stdin
+	version  bool // print the build info and exit
+	bench    int  // frames to simulate headlessly, 0 to play normally
+	headless bool // play one run with no terminal and print its results
+	resume   bool // carry on with the run saved when the last one was quit
+	checksum bool // play one seeded run with no terminal and print hashes of it
+
+	args   []string      // the flags as given, saved with the run so -resume can use them again
+	script []courseEvent // the -course script, read and checked
+
+	logPath string // structured event log, empty for none
+	output  string // where frames go: stdout, stderr or a path
+	narrate string // where spoken event lines go: stdout, stderr or a path, empty for none
+	cast    string // asciinema recording of the session, empty for none
+	share   string // where the run's share card goes when it's done, empty for nowhere
+	metrics string // address to serve Prometheus metrics on, empty for none
+	course  string // spawn script that replaces the random spawner, empty for none
+
+	width, height int    // forced screen size, 0 to follow the terminal
+	geometry      bool   // forced size taken on trust, the terminal's never asked
+	minW, minH    int    // smallest screen the run plays on
+	tooSmall      string // what happens below that, see the too-small constants
+
+	view          string    // perspective or topdown
+	laneEasing    string    // how lane changes move, one of easings
+	hudPos        string    // where the HUD goes, see the HUD position constants
+	hudLayout     hudLayout // rows from -hud-layout, nil for the built-in HUD
+	scoreFormat   string    // how scores are written, see the score format constants
+	scoreSep      string    // what goes between groups of digits in grouped scores
+	straight      bool      // no curves in the track
+	trackScale    float64   // track width as a share of the terminal, 0 for classic
+	reducedMotion bool      // skip purely decorative motion effects
+	zen           bool      // no HUD or banners, faint calm colours, see zen.go
+	noAltScreen   bool      // play inline at the bottom of the screen, leaving the last frame in the scrollback
+	retro         bool      // blocky look, nothing drawn between lanes or part-grown
+	halfBlock     bool      // ground drawn in half-block pixels at twice the rows
+	debugLanes    bool      // draw the lane occupancy overlay
+	debugRender   bool      // panic on a rendered row or frame that would throw the layout off
+	speedometer   bool      // draw a speed gauge in the HUD
+	coinMarkers   bool      // mark lanes with coins coming on the horizon
+	laneMarker    bool      // lane gauge along the bottom showing which lane the runner's in
+	skyChar       string    // what the stars are drawn with, one ASCII character
+	skyEvery      int       // rows between rows of stars, 0 for none
+	groundChar    string    // what the ground specks are drawn with, one ASCII character
+	groundEvery   int       // columns between ground specks, 0 for none
+	strideRate    float64   // runner's steps per second at the start speed
+	jumpSecs      float64   // hang time of a jump
+	jumpHeight    int       // rows a jump peaks at
+	hints         bool      // show the controls bar at the start of a run
+}
+
+var difficulties = map[string]config{
+	"easy": {
+		difficulty:    "easy",
+		baseSpeed:     5.0,
+		speedRamp:     0.03,
+		maxSpeed:      12.0,
+		mode:          modeForgiving,
+		lives:         5,
+		leniency:      0.15,
+		coinWindow:    3.0,
+		coinValue:     40,
+		maxObjects:    20,
+		waveAmplitude: 0.3,
+		wavePeriod:    20,
+	},
+	"normal": {
+		difficulty:    "normal",
+		baseSpeed:     6.0,
+		speedRamp:     0.05,
+		maxSpeed:      16.0,
+		mode:          modeForgiving,
+		lives:         3,
+		leniency:      0.08,
+		coinWindow:    2.0,
+		coinValue:     50,
+		maxObjects:    30,
+		waveAmplitude: 0.4,
+		wavePeriod:    20,
+	},
+	"hard": {
+		difficulty:    "hard",
+		baseSpeed:     8.0,
+		speedRamp:     0.08,
+		maxSpeed:      22.0,
+		mode:          modeForgiving,
+		lives:         2,
+		leniency:      0,
+		coinWindow:    1.5,
+		coinValue:     60,
+		maxObjects:    40,
+		waveAmplitude: 0.5,
+		wavePeriod:    20,
+	},
+	// Keeps speeding up until you inevitably crash
+	"survival": {
+		difficulty:    "survival",
+		baseSpeed:     6.0,
+		speedRamp:     0.1,
+		maxSpeed:      0,
+		mode:          modeHardcore,
+		lives:         1,
+		leniency:      0,
+		coinWindow:    2.0,
+		coinValue:     50,
+		maxObjects:    40,
+		waveAmplitude: 0.4,
+		wavePeriod:    20,
+	},
+}
+
+func difficultyNames() string {
+	names := make([]string, 0, len(difficulties))
+	for name := range difficulties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func newFlagSet(cfg *config) *flag.FlagSet {
+	fs := flag.NewFlagSet("subway-surfer", flag.ContinueOnError)
+	fs.StringVar(&cfg.difficulty, "difficulty", cfg.difficulty, "difficulty preset: "+difficultyNames())
+	fs.Float64Var(&cfg.speedRamp, "speed-ramp", cfg.speedRamp, "speed gained per second, or per second's worth of -ramp")
+	fs.StringVar(&cfg.rampBy, "ramp", rampTime, "what the speed goes up with: time, distance or score")
+	fs.Float64Var(&cfg.maxSpeed, "max-speed", cfg.maxSpeed, "speed cap (0 for uncapped)")
+	fs.Float64Var(&cfg.baseSpeed, "start-speed", cfg.baseSpeed, "speed at the start of a run")
+	fs.IntVar(&cfg.startScore, "start-score", cfg.startScore, "score to start the run with")
+	fs.IntVar(&cfg.lives, "lives", cfg.lives, "lives to start with in forgiving mode")
+	fs.Float64Var(&cfg.leniency, "leniency", cfg.leniency, "seconds after starting a lane change that a hit in the lane you're leaving is forgiven")
+	fs.Float64Var(&cfg.maxStep, "max-step", 0.1, "longest the game moves on in one frame after a hitch, in seconds. longer frames play in slow motion")
+	fs.StringVar(&cfg.mode, "mode", cfg.mode, "what a crash does: hardcore (run over), forgiving (lose a life) or practice (lose points)")
+	fs.Float64Var(&cfg.farZ, "view-distance", cfg.farZ, "how far down the track you can see, further makes everything nearer smaller")
+	fs.Float64Var(&cfg.spawnZ, "spawn-distance", 0, fmt.Sprintf("how far down the track things come in, short of -view-distance (default %d short of it)", spawnInset))
+	fs.Float64Var(&cfg.lookahead, "lookahead", cfg.lookahead, "how far down the track the autopilot looks for trains to dodge, shorter leaves it less time")
+	fs.Float64Var(&cfg.coinWindow, "coin-window", cfg.coinWindow, "how close coins have to get to be grabbed, bigger is easier")
+	fs.Float64Var(&cfg.coinSpacing, "coin-spacing", 1.5, "track between the coins in a line, smaller is tighter")
+	fs.Float64Var(&cfg.patternSpacing, "pattern-spacing", 1, "stretch (above 1) or squash (below 1) the time between the steps of a set piece")
+	fs.IntVar(&cfg.coinLaneReach, "coin-lanes", cfg.coinLaneReach, "also grab coins this many lanes either side of the runner")
+	fs.IntVar(&cfg.coinValue, "coin-value", cfg.coinValue, "points per coin")
+	fs.Float64Var(&cfg.distanceValue, "distance-value", defaultDistanceValue, "points per unit of track covered, lower to make it more about the coins")
+	fs.IntVar(&cfg.maxObjects, "max-objects", cfg.maxObjects, "most obstacles and coins on screen at once, for readability")
+	fs.Float64Var(&cfg.waveAmplitude, "wave-amplitude", cfg.waveAmplitude, "how far obstacle density swings between calm and dense (0 to turn waves off, below 1)")
+	fs.Float64Var(&cfg.wavePeriod, "wave-period", cfg.wavePeriod, "seconds for a full calm-to-dense obstacle wave")
+	fs.BoolFunc("uncapped", "never stop speeding up (same as -max-speed 0)", func(string) error {
+		cfg.maxSpeed = 0
+		return nil
+	})
+	fs.Float64Var(&cfg.levelLength, "level", 0, "race to a finish line this far down the track (0 for endless)")
+	fs.Float64Var(&cfg.rushEvery, "rush-every", 45, "seconds between coin rushes, when obstacles stop and coins pour in (0 for none)")
+	fs.Float64Var(&cfg.rushSecs, "rush-secs", 5, "how long a coin rush lasts in seconds")
+	fs.Float64Var(&cfg.closureEvery, "closure-every", 40, "seconds between an outside lane catching fire for a stretch (0 for never)")
+	fs.Float64Var(&cfg.gapEvery, "gap-every", 30, "seconds between the ground dropping away across the track, to jump over (0 for never)")
+	fs.Func("seed", "play a fixed course from this seed", func(v string) error {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errors.New("seed must be an integer")
+		}
+		cfg.seed, cfg.seeded = seed, true
+		return nil
+	})
+	fs.BoolVar(&cfg.daily, "daily", false, "play today's course, the same for everyone")
+	fs.BoolVar(&cfg.manual, "manual", false, "steer yourself: a/d move, w or space jump, s slide")
+	fs.BoolVar(&cfg.challenge, "challenge", false, "start the same course over straight after losing, and count the tries (pair it with -seed)")
+	fs.BoolVar(&cfg.learn, "learn", false, "learning mode: obstacles count down the ticks until they reach you and the safe lane's marked > <. never ranked")
+	fs.StringVar(&cfg.quitKeys, "quit-keys", "q", "keys that quit, e.g. qx (empty for none)")
+	fs.BoolVar(&cfg.ctrlC, "ctrl-c", true, "let ctrl-c quit (-ctrl-c=false for kiosks, the game then only ends on a quit key or SIGTERM)")
+	fs.BoolVar(&cfg.demo, "demo", false, "watch without reading keys, quit with ctrl-c")
+	skipIntro, _ := strconv.ParseBool(os.Getenv("SUBWAY_SURFER_SKIP_INTRO"))
+	fs.BoolVar(&cfg.skipIntro, "skip-intro", skipIntro, "go straight into the run, no title or countdown (default from $SUBWAY_SURFER_SKIP_INTRO)")
+	fs.BoolVar(&cfg.version, "version", false, "print the version and build info, then exit")
+	fs.IntVar(&cfg.bench, "bench", 0, "simulate and render this many frames headlessly, then print timings")
+	fs.BoolVar(&cfg.headless, "headless", false, "play one run on autopilot with no terminal, then print its results as JSON")
+	fs.BoolVar(&cfg.checksum, "checksum", false, "play one seeded run on autopilot with no terminal, then print hashes of its final state and every frame, for CI")
+	fs.BoolVar(&cfg.resume, "resume", false, "carry on with the run saved when you last quit partway through one, with the flags it had")
+	fs.StringVar(&cfg.output, "output", "stdout", "draw to stdout, stderr, or a path like another terminal's tty")
+	fs.BoolVar(&cfg.noAltScreen, "no-altscreen", false, "play inline at the bottom of the terminal instead of on the alternate screen, so the last frame stays in the scrollback")
+	setSize := func(v string) error {
+		w, h, ok := parseSize(v)
+		if !ok {
+			return errors.New("size must look like 80x24")
+		}
+		cfg.width, cfg.height = w, h
+		return nil
+	}
+	fs.Func("size", "play at a fixed WxH size like 80x24 instead of following the terminal", func(v string) error {
+		cfg.geometry = false
+		return setSize(v)
+	})
+	fs.Func("geometry", "like -size, but never asks the terminal its size at all, not even to clip to it", func(v string) error {
+		cfg.geometry = true
+		return setSize(v)
+	})
+	cfg.minW, cfg.minH = minPlayWidth, minPlayHeight
+	fs.Func("min-size", fmt.Sprintf("smallest WxH the run plays on (default %dx%d)", minPlayWidth, minPlayHeight), func(v string) error {
+		w, h, ok := parseSize(v)
+		if !ok {
+			return errors.New("min size must look like 80x24")
+		}
+		cfg.minW, cfg.minH = w, h
+		return nil
+	})
+	fs.StringVar(&cfg.tooSmall, "too-small", tooSmallWait, "what happens on a terminal smaller than -min-size: wait (hold the run until it's resized) or clamp (play on and cut off the edges)")
+	fs.StringVar(&cfg.logPath, "log", "", "write structured game events to this file")
+	fs.StringVar(&cfg.narrate, "narrate", "", "say what's happening as lines of text for a screen reader, to stdout, stderr or a path")
+	fs.StringVar(&cfg.cast, "cast", "", "record the session to this file as an asciinema cast")
+	fs.StringVar(&cfg.share, "share-card", "", "write the run's share card, a little box of how it went, to this file when you quit after it ends")
+	fs.StringVar(&cfg.metrics, "metrics", "", "serve Prometheus metrics (score, games, uptime, fps) over HTTP on this address, like :9100")
+	fs.StringVar(&cfg.course, "course", "", "play an authored course from this spawn script instead of random spawns")
+	fs.StringVar(&cfg.hudPos, "hud", hudTopRight, "where the score and friends go: top-right, top-left or bottom")
+	fs.Func("hud-layout", "your own HUD rows, fields in braces and | between rows, e.g. \"S:{score} C:{coins}|{speed}m/s\"", func(v string) error {
+		layout, err := parseHUDLayout(v)
+		cfg.hudLayout = layout
+		return err
+	})
+	fs.StringVar(&cfg.scoreFormat, "score-format", scorePadded, "how scores are written: padded (0012345), plain (12345), grouped (12,345) or compact (12.3k)")
+	fs.StringVar(&cfg.scoreSep, "score-sep", ",", "what goes between groups of digits in grouped scores, e.g. . or a space")
+	fs.StringVar(&cfg.view, "view", viewPerspective, "how to look at the track: perspective or topdown")
+	fs.StringVar(&cfg.laneEasing, "lane-easing", easeLinear, "how lane changes move: linear, ease-in-out or overshoot")
+	fs.BoolVar(&cfg.straight, "straight", false, "keep the track dead straight, the classic look")
+	fs.Float64Var(&cfg.trackScale, "track-width", 0, "track width as a share of the terminal, e.g. 0.5 (0 for the classic fixed width)")
+	fs.BoolVar(&cfg.reducedMotion, "reduced-motion", false, "turn off decorative motion effects")
+	fs.BoolVar(&cfg.zen, "zen", false, "just run: no HUD or banners, calm faint colours, and practice mode unless -mode says otherwise")
+	fs.BoolVar(&cfg.halfBlock, "halfblock", false, "draw the track at twice the rows with coloured half blocks (needs a terminal with Unicode and colour)")
+	fs.BoolVar(&cfg.retro, "retro", false, "blocky retro look: the runner and coins jump lane to lane, no leaning, no pop-in")
+	fs.BoolVar(&cfg.hints, "hints", true, "show the controls along the bottom for the first few seconds (? toggles them)")
+	fs.Float64Var(&cfg.strideRate, "stride-rate", 8, "runner's steps per second at the start speed, quicker as the speed ramps")
+	fs.Float64Var(&cfg.jumpSecs, "jump-secs", actionSecs, "how long a jump stays in the air, longer is floatier")
+	fs.IntVar(&cfg.jumpHeight, "jump-height", 2, "how many rows a jump peaks at")
+	fs.BoolVar(&cfg.speedometer, "speedometer", false, "show a speed gauge under the score")
+	fs.StringVar(&cfg.skyChar, "sky-char", ".", "character the stars are drawn with")
+	fs.IntVar(&cfg.skyEvery, "sky-every", 3, "stars on every this many rows of sky, bigger is sparser (0 for none)")
+	fs.StringVar(&cfg.groundChar, "ground-char", ".", "character the ground texture is drawn with")
+	fs.IntVar(&cfg.groundEvery, "ground-every", 5, "a ground speck every this many columns, bigger is sparser (0 for bare ground)")
+	fs.BoolVar(&cfg.coinMarkers, "coin-markers", true, "mark lanes with coins coming on the horizon, before the coins themselves show")
+	fs.BoolVar(&cfg.laneMarker, "lane-marker", false, "show which lane you're in along the bottom, a ^ under a row of lane slots")
+	fs.BoolVar(&cfg.debugLanes, "debug-lanes", false, "show per-lane obstacle and dodge state")
+	tuneFlags(fs, cfg)
+	fs.BoolVar(&cfg.debugRender, "debug-render", false, "crash on any rendered row that isn't exactly the screen width of plain ASCII, or a frame that doesn't decode back to the screen")
+	return fs
+}
+
+// parseConfig picks a difficulty preset, then applies the remaining flags on
+// top of it so anything set explicitly wins over the preset.
+func parseConfig(args []string) (config, error) {
+	cfg := difficulties["normal"]
+	if err := newFlagSet(&cfg).Parse(args); err != nil {
+		return cfg, err
+	}
+
+	preset, ok := difficulties[cfg.difficulty]
+	if !ok {
+		return cfg, fmt.Errorf("unknown difficulty %q (want one of: %s)", cfg.difficulty, difficultyNames())
+	}
+	if cfg.zen {
+		preset.mode = modePractice
+	}
+	cfg = preset
+	cfg.spawnScale, cfg.farZ, cfg.lookahead = 1, defaultFarZ, dodgeLookahead
+	fs := newFlagSet(&cfg)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	cfg.args = args
+
+	if cfg.resume && fs.NFlag() > 1 {
+		return cfg, errors.New("-resume uses the flags the saved run had, so it can't be given others")
+	}
+
+	if cfg.daily {
+		if cfg.seeded {
+			return cfg, errors.New("-seed and -daily can't be used together")
+		}
+		cfg.seed, cfg.seeded = dailySeed(time.Now()), true
+	}
+
+	switch cfg.mode {
+	case modeHardcore, modeForgiving, modePractice:
+	default:
+		return cfg, fmt.Errorf("unknown mode %q (want hardcore, forgiving or practice)", cfg.mode)
+	}
+	if cfg.lives < 1 || cfg.lives > maxLives {
+		return cfg, fmt.Errorf("lives must be from 1 to %d, got %d", maxLives, cfg.lives)
+	}
+	if cfg.baseSpeed <= 0 || cfg.baseSpeed > maxStartSpeed {
+		return cfg, fmt.Errorf("start speed must be above 0 and at most %d, got %v", maxStartSpeed, cfg.baseSpeed)
+	}
+	if cfg.startScore < 0 {
+		return cfg, fmt.Errorf("start score can't be negative, got %d", cfg.startScore)
+	}
+	if cfg.leniency < 0 || cfg.leniency > maxLeniency {
+		return cfg, fmt.Errorf("leniency must be from 0 to %v seconds, got %v", maxLeniency, cfg.leniency)
+	}
+	if cfg.maxStep < 1.0/targetFPS || cfg.maxStep > maxMaxStep {
+		return cfg, fmt.Errorf("max step must be from %v (a frame) to %v seconds, got %v", 1.0/targetFPS, maxMaxStep, cfg.maxStep)
+	}
+	cfg.handicap = cfg.baseSpeed != preset.baseSpeed || cfg.startScore != 0 || cfg.lives != preset.lives
+	if cfg.farZ < minViewDistance || cfg.farZ > maxViewDistance {
+		return cfg, fmt.Errorf("view distance must be from %v to %v, got %v", minViewDistance, maxViewDistance, cfg.farZ)
+	}
+	if cfg.spawnZ == 0 {
+		cfg.spawnZ = cfg.farZ - spawnInset
+	}
+	if cfg.spawnZ <= pullZ || cfg.spawnZ >= cfg.farZ {
+		return cfg, fmt.Errorf("spawn distance must be above %v and short of the view distance (%v), got %v", pullZ, cfg.farZ, cfg.spawnZ)
+	}
+	if cfg.lookahead < runnerZ || cfg.lookahead > cfg.spawnZ {
+		return cfg, fmt.Errorf("lookahead must be from %v up to the spawn distance (%v), got %v", runnerZ, cfg.spawnZ, cfg.lookahead)
+	}
+	if cfg.coinSpacing < minCoinSpacing || cfg.coinSpacing > maxCoinSpacing {
+		return cfg, fmt.Errorf("coin spacing must be from %v to %v, got %v", minCoinSpacing, maxCoinSpacing, cfg.coinSpacing)
+	}
+	if cfg.coinWindow <= 0 || cfg.coinWindow > cfg.spawnZ {
+		return cfg, fmt.Errorf("coin window must be above 0 and at most the spawn distance (%v), got %v", cfg.spawnZ, cfg.coinWindow)
+	}
+	if cfg.coinLaneReach < 0 || cfg.coinLaneReach >= numLanes {
+		return cfg, fmt.Errorf("coin lanes must be from 0 to %d, got %d", numLanes-1, cfg.coinLaneReach)
+	}
+	if cfg.coinValue < 0 {
+		return cfg, fmt.Errorf("coin value can't be negative, got %d", cfg.coinValue)
+	}
+	if cfg.distanceValue < 0 {
+		return cfg, fmt.Errorf("distance value can't be negative, got %v", cfg.distanceValue)
+	}
+	if limit := obstaclePoolSize + coinPoolSize; cfg.maxObjects < minObjects || cfg.maxObjects > limit {
+		return cfg, fmt.Errorf("max objects must be from %d to %d, got %d", minObjects, limit, cfg.maxObjects)
+	}
+	if cfg.waveAmplitude < 0 || cfg.waveAmplitude >= 1 {
+		return cfg, fmt.Errorf("wave amplitude must be from 0 up to 1, got %v", cfg.waveAmplitude)
+	}
+	if cfg.wavePeriod <= 0 {
+		return cfg, fmt.Errorf("wave period must be positive, got %v", cfg.wavePeriod)
+	}
+	if cfg.levelLength < 0 {
+		return cfg, fmt.Errorf("level length can't be negative, got %v", cfg.levelLength)
+	}
+	if cfg.rushEvery < 0 {
+		return cfg, fmt.Errorf("rush interval can't be negative, got %v", cfg.rushEvery)
+	}
+	if cfg.rushEvery > 0 && (cfg.rushSecs <= 0 || cfg.rushSecs >= cfg.rushEvery) {
+		return cfg, fmt.Errorf("rush length must be above 0 and shorter than the time between rushes, got %v", cfg.rushSecs)
+	}
+	if cfg.closureEvery < 0 {
+		return cfg, fmt.Errorf("closure interval can't be negative, got %v", cfg.closureEvery)
+	}
+	if cfg.gapEvery < 0 {
+		return cfg, fmt.Errorf("gap interval can't be negative, got %v", cfg.gapEvery)
+	}
+	if cfg.jumpSecs < minJumpSecs || cfg.jumpSecs > maxJumpSecs {
+		return cfg, fmt.Errorf("jump secs must be from %v to %v, got %v", minJumpSecs, maxJumpSecs, cfg.jumpSecs)
+	}
+	if cfg.patternSpacing < minPatternSpacing || cfg.patternSpacing > maxPatternSpacing {
+		return cfg, fmt.Errorf("pattern spacing must be from %v to %v, got %v", minPatternSpacing, maxPatternSpacing, cfg.patternSpacing)
+	}
+	// jump-then-slide's bar comes a second after its wall, unscaled: the
+	// runner needs to land from a jump that peaks on the wall in time to
+	// start a slide that's lowest under the bar
+	if cfg.patternSpacing < (cfg.jumpSecs+actionSecs)/2 {
+		return cfg, fmt.Errorf("pattern spacing %v leaves no time to land before the slide in jump-then-slide, at -jump-secs %v it has to be at least %v", cfg.patternSpacing, cfg.jumpSecs, (cfg.jumpSecs+actionSecs)/2)
+	}
+
+	// Anything that changes the course or how it plays makes a run that
+	// can't be held against the seed's best, so like a handicap it races the
+	// best but doesn't set one. That includes -tune, which turns the knobs
+	// mid-run. What's stock is whatever the flags default to on the preset
+	stock := preset
+	stock.spawnScale, stock.farZ, stock.lookahead = 1, defaultFarZ, dodgeLookahead
+	newFlagSet(&stock)
+	stock.spawnZ = stock.farZ - spawnInset
+	cfg.tuned = cfg.tune || cfg.course != "" ||
+		cfg.speedRamp != stock.speedRamp || cfg.rampBy != stock.rampBy || cfg.maxSpeed != stock.maxSpeed ||
+		cfg.leniency != stock.leniency || cfg.spawnScale != stock.spawnScale ||
+		cfg.farZ != stock.farZ || cfg.spawnZ != stock.spawnZ || cfg.lookahead != stock.lookahead ||
+		cfg.coinWindow != stock.coinWindow || cfg.coinLaneReach != stock.coinLaneReach ||
+		cfg.coinSpacing != stock.coinSpacing || cfg.patternSpacing != stock.patternSpacing ||
+		cfg.maxObjects != stock.maxObjects || cfg.waveAmplitude != stock.waveAmplitude || cfg.wavePeriod != stock.wavePeriod ||
+		cfg.levelLength != stock.levelLength || cfg.rushEvery != stock.rushEvery || cfg.rushSecs != stock.rushSecs ||
+		cfg.closureEvery != stock.closureEvery || cfg.gapEvery != stock.gapEvery || cfg.jumpSecs != stock.jumpSecs
+
+	if cfg.jumpHeight < 1 || cfg.jumpHeight > maxJumpHeight {
+		return cfg, fmt.Errorf("jump height must be from 1 to %d rows, got %d", maxJumpHeight, cfg.jumpHeight)
+	}
+	if !utf8.ValidString(cfg.quitKeys) {
+		return cfg, fmt.Errorf("quit keys must be UTF-8, got %q", cfg.quitKeys)
+	}
+	if !fillChar(cfg.skyChar) {
+		return cfg, fmt.Errorf("sky char must be one printable ASCII character, got %q", cfg.skyChar)
+	}
+	if !fillChar(cfg.groundChar) {
+		return cfg, fmt.Errorf("ground char must be one printable ASCII character, got %q", cfg.groundChar)
+	}
+	if cfg.skyEvery < 0 || cfg.groundEvery < 0 {
+		return cfg, fmt.Errorf("sky and ground spacing can't be negative, got %d and %d", cfg.skyEvery, cfg.groundEvery)
+	}
+	if cfg.view != viewPerspective && cfg.view != viewTopDown {
+		return cfg, fmt.Errorf("unknown view %q (want perspective or topdown)", cfg.view)
+	}
+	if cfg.width > 0 && (cfg.width < cfg.minW || cfg.height < cfg.minH) {
+		return cfg, fmt.Errorf("size must be at least %dx%d to play on, see -min-size", cfg.minW, cfg.minH)
+	}
+	if _, ok := easings[cfg.laneEasing]; !ok {
+		return cfg, fmt.Errorf("unknown lane easing %q (want linear, ease-in-out or overshoot)", cfg.laneEasing)
+	}
+	if cfg.tooSmall != tooSmallWait && cfg.tooSmall != tooSmallClamp {
+		return cfg, fmt.Errorf("unknown too-small behaviour %q (want wait or clamp)", cfg.tooSmall)
+	}
+	if cfg.halfBlock && cfg.view != viewPerspective {
+		return cfg, errors.New("-halfblock only draws the perspective view")
+	}
+	switch cfg.hudPos {
+	case hudTopRight, hudTopLeft, hudBottom:
+	default:
+		return cfg, fmt.Errorf("unknown HUD position %q (want top-right, top-left or bottom)", cfg.hudPos)
+	}
+	limit := defaultWidth
+	if cfg.width > 0 {
+		limit = cfg.width
+	}
+	if w := cfg.hudLayout.widest(cfg); w >= limit {
+		return cfg, fmt.Errorf("a HUD layout row can get %d wide, too much for a %d column screen", w, limit)
+	}
+	switch cfg.scoreFormat {
+	case scorePadded, scorePlain, scoreGrouped, scoreCompact:
+	default:
+		return cfg, fmt.Errorf("unknown score format %q (want padded, plain, grouped or compact)", cfg.scoreFormat)
+	}
+	if !fillChar(cfg.scoreSep) {
+		return cfg, fmt.Errorf("score separator must be one printable ASCII character, got %q", cfg.scoreSep)
+	}
+	if cfg.trackScale < 0 || cfg.trackScale > 1 {
+		return cfg, fmt.Errorf("track width must be a share of the terminal from 0 to 1, got %v", cfg.trackScale)
+	}
+	if cfg.strideRate < 0 {
+		return cfg, fmt.Errorf("stride rate can't be negative, got %v", cfg.strideRate)
+	}
+	if cfg.manual && (cfg.demo || cfg.headless || cfg.checksum) {
+		return cfg, errors.New("-manual needs keys, so it can't be used with -demo, -headless or -checksum")
+	}
+	if cfg.headless && cfg.bench > 0 {
+		return cfg, errors.New("-headless and -bench can't be used together")
+	}
+	if cfg.checksum && (cfg.headless || cfg.bench > 0) {
+		return cfg, errors.New("-checksum can't be used with -headless or -bench")
+	}
+	if cfg.checksum && !cfg.seeded {
+		return cfg, errors.New("-checksum needs a fixed course, give it a -seed")
+	}
+	if cfg.narrate != "" && (cfg.headless || cfg.checksum || cfg.bench > 0) {
+		return cfg, errors.New("-narrate talks through a run as it's played, so it can't be used with -headless, -checksum or -bench")
+	}
+	if cfg.narrate != "" && cfg.narrate == cfg.output {
+		return cfg, fmt.Errorf("-narrate and -output can't both go to %s, send the frames elsewhere with -output /dev/null", cfg.narrate)
+	}
+	if cfg.share != "" && (cfg.headless || cfg.checksum || cfg.bench > 0) {
+		return cfg, errors.New("-share-card is written when you quit a game you played, so it can't be used with -headless, -checksum or -bench")
+	}
+	if cfg.metrics != "" && (cfg.headless || cfg.checksum || cfg.bench > 0) {
+		return cfg, errors.New("-metrics is for a game left running on a display, so it can't be used with -headless, -checksum or -bench")
+	}
+	if _, _, err := net.SplitHostPort(cfg.metrics); cfg.metrics != "" && err != nil {
+		return cfg, fmt.Errorf("metrics address must look like :9100 or host:9100, got %q", cfg.metrics)
+	}
+	if cfg.bench < 0 {
+		return cfg, fmt.Errorf("bench frame count can't be negative, got %d", cfg.bench)
+	}
+
+	// The script decides everything that spawns, so there are no coin rushes
+	// lane closures or gaps on an authored course either
+	if cfg.course != "" {
+		script, err := loadCourse(cfg.course)
+		if err != nil {
+			return cfg, fmt.Errorf("couldn't load course: %w", err)
+		}
+		cfg.script, cfg.rushEvery, cfg.closureEvery, cfg.gapEvery = script, 0, 0, 0
+	}
+
+	if cfg.speedRamp < 0 {
+		return cfg, fmt.Errorf("speed ramp can't be negative, got %v", cfg.speedRamp)
+	}
+	switch cfg.rampBy {
+	case rampTime, rampDistance:
+	case rampScore:
+		if cfg.distanceValue == 0 {
+			return cfg, errors.New("-ramp score needs track to be worth points, -distance-value can't be 0")
+		}
+	default:
+		return cfg, fmt.Errorf("unknown ramp %q (want time, distance or score)", cfg.rampBy)
+	}
+	if cfg.maxSpeed < 0 {
+		return cfg, fmt.Errorf("max speed can't be negative, got %v", cfg.maxSpeed)
+	}
+	if cfg.maxSpeed > 0 && cfg.maxSpeed < cfg.baseSpeed {
+		return cfg, fmt.Errorf("max speed %v is below start speed %v", cfg.maxSpeed, cfg.baseSpeed)
+	}
+
+	return cfg, nil
+}
+
+// quits reports whether key k ends the game.
+func (c config) quits(k keyPress) bool {
+	return (k <= utf8.MaxRune && strings.ContainsRune(c.quitKeys, rune(k))) || (k == 3 && c.ctrlC)
+}
+
+// quitHint tells the player how to quit, or is empty if they can't from
+// where they're sitting.
+func (c config) quitHint() string {
+	switch {
+	case !c.demo && c.quitKeys != "":
+		return "press " + c.quitKeyName() + " to quit"
+	case c.ctrlC:
+		return "ctrl-c to quit"
+	}
+	return ""
+}
+
+// controlsHint is the controls bar, listing just the keys that do something
+// in this run.
+func (c config) controlsHint() string {
+	hint := " "
+	if c.manual {
+		hint += "a/d move  w/space jump  s slide  "
+	}
+	hint += "p pause  "
+	if c.quitKeys != "" {
+		hint += c.quitKeyName() + " quit  "
+	}
+	return hint + "? hide "
+}
+
+// scoreText writes a score in the -score-format style. Only the HUD's widest
+// lines have room to pad, so everywhere else passes pad false and gets it
+// without the zeros. Scores never go below 0, practice hits stop there, and
+// the pace gap writes its own sign, so n is never negative in the game; the
+// helpers still keep a sign rather than mangle one.
+func (c config) scoreText(n int, pad bool) string {
+	switch c.scoreFormat {
+	case scoreGrouped:
+		return groupDigits(n, c.scoreSep)
+	case scoreCompact:
+		return compactNumber(n)
+	case scorePadded:
+		if pad {
+			return fmt.Sprintf("%07d", n)
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+// groupDigits writes n with sep between each group of three digits.
+func groupDigits(n int, sep string) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	if n < 0 {
+		b.WriteByte('-')
+		digits = digits[1:]
+	}
+	for i := range len(digits) {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteByte(digits[i])
+	}
+	return b.String()
+}
+
+// compactNumber writes n to at most one decimal place with a k, M, B or T,
+// like 12.3k. It rounds toward zero so it never shows more than n: 999,999 is
+// 999.9k, not 1M.
+func compactNumber(n int) string {
+	sign, u := "", uint64(n)
+	if n < 0 {
+		sign, u = "-", -u
+	}
+	if u < 1000 {
+		return sign + strconv.FormatUint(u, 10)
+	}
+	const units = "kMBT"
+	scale, i := uint64(1000), 0
+	for u/scale >= 1000 && i < len(units)-1 {
+		scale *= 1000
+		i++
+	}
+	tenths := u / (scale / 10)
+	s := strconv.FormatUint(tenths/10, 10)
+	if d := tenths % 10; d != 0 {
+		s += "." + strconv.FormatUint(d, 10)
+	}
+	return sign + s + units[i:i+1]
+}
+
+// quitKeyName is the quit key hints name. The HUD's widths go by bytes, so
+// it's the first quit key that's plain ASCII, or the first one's code point
+// if none is.
+func (c config) quitKeyName() string {
+	for _, r := range c.quitKeys {
+		if r < utf8.RuneSelf {
+			return keyName(r)
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(c.quitKeys)
+	return fmt.Sprintf("U+%04X", r)
+}
+
+// keyName is how an ASCII key is written in on-screen hints.
+func keyName(k rune) string {
+	switch {
+	case k == ' ':
+		return "space"
+	case k == keyEsc:
+		return "esc"
+	case k == 127:
+		return "del"
+	case k < ' ':
+		return "ctrl-" + string('a'+k-1)
+	}
+	return string(k)
+}
+
+// parseSize reads a WxH size, both parts positive.
+func parseSize(v string) (w, h int, ok bool) {
+	ws, hs, found := strings.Cut(strings.ToLower(v), "x")
+	if !found {
+		return 0, 0, false
+	}
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(hs)
+	if werr != nil || herr != nil || w < 1 || h < 1 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// fillChar reports whether s is a single printable ASCII character, the only
+// kind that can be dropped into a frame without throwing the columns off.
+func fillChar(s string) bool {
+	return len(s) == 1 && printable(s)
+}
+
+// printable reports whether s is all printable ASCII, a column a byte.
+func printable(s string) bool {
+	for i := range len(s) {
+		if s[i] < ' ' || s[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// dailySeed turns a date into a seed like 20261015, so everyone playing on the
+// same day gets the same course.
+func dailySeed(t time.Time) int64 {
+	y, m, d := t.Date()
+	return int64(y*10000 + int(m)*100 + d)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuitHint(t *testing.T) {
+	tests := []struct {
+		keys     string
+		quitHint string
+		controls string
+	}{
+		{"q", "press q to quit", " p pause  q quit  ? hide "},
+		{"xq", "press x to quit", " p pause  x quit  ? hide "},
+		{" ", "press space to quit", " p pause  space quit  ? hide "},
+		{"\x1b", "press esc to quit", " p pause  esc quit  ? hide "},
+		{"\x11", "press ctrl-q to quit", " p pause  ctrl-q quit  ? hide "},
+		{"\x7f", "press del to quit", " p pause  del quit  ? hide "},
+		{"éq", "press q to quit", " p pause  q quit  ? hide "},
+		{"é", "press U+00E9 to quit", " p pause  U+00E9 quit  ? hide "},
+		{"日本", "press U+65E5 to quit", " p pause  U+65E5 quit  ? hide "},
+		{"", "ctrl-c to quit", " p pause  ? hide "},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, "-quit-keys", tt.keys)
+		if got := cfg.quitHint(); got != tt.quitHint {
+			t.Errorf("quit keys %q: quitHint = %q, want %q", tt.keys, got, tt.quitHint)
+		}
+		if got := cfg.controlsHint(); got != tt.controls {
+			t.Errorf("quit keys %q: controlsHint = %q, want %q", tt.keys, got, tt.controls)
+		}
+
+		// The controls bar and title go through -debug-render, which
+		// panics on anything but ASCII
+		g := testGame(t, 80, 24, "-quit-keys", tt.keys, "-hints", "-debug-render")
+		screenRows(g)
+	}
+}
+
+func TestQuitKeysMustBeUTF8(t *testing.T) {
+	if _, err := parseConfig([]string{"-quit-keys", "q\xff"}); err == nil {
+		t.Error("took quit keys that aren't UTF-8")
+	}
+}
+
+func TestScoreText(t *testing.T) {
+	tests := []struct {
+		format string
+		n      int
+		pad    bool
+		want   string
+	}{
+		{scorePadded, 12345, true, "0012345"},
+		{scorePadded, 12345, false, "12345"},
+		{scorePadded, 0, true, "0000000"},
+		{scorePadded, 123456789, true, "123456789"},
+		{scorePlain, 12345, true, "12345"},
+		{scoreGrouped, 1234567, true, "1,234,567"},
+		{scoreGrouped, 999, false, "999"},
+		{scoreCompact, 12345, true, "12.3k"},
+		{scoreCompact, 999, true, "999"},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, "-score-format", tt.format)
+		if got := cfg.scoreText(tt.n, tt.pad); got != tt.want {
+			t.Errorf("%s: scoreText(%d, %v) = %q, want %q", tt.format, tt.n, tt.pad, got, tt.want)
+		}
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	tests := []struct {
+		n    int
+		sep  string
+		want string
+	}{
+		{0, ",", "0"},
+		{999, ",", "999"},
+		{1000, ",", "1,000"},
+		{12345, ".", "12.345"},
+		{123456, " ", "123 456"},
+		{1234567, ",", "1,234,567"},
+		{-1234, ",", "-1,234"},
+		{-123, ",", "-123"},
+		{math.MaxInt64, ",", "9,223,372,036,854,775,807"},
+		{math.MinInt64, ",", "-9,223,372,036,854,775,808"},
+	}
+	for _, tt := range tests {
+		if got := groupDigits(tt.n, tt.sep); got != tt.want {
+			t.Errorf("groupDigits(%d, %q) = %q, want %q", tt.n, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestCompactNumber(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1k"},
+		{1049, "1k"},
+		{1099, "1k"},
+		{1100, "1.1k"},
+		{12345, "12.3k"},
+		{99999, "99.9k"},
+		{999999, "999.9k"},
+		{1000000, "1M"},
+		{1250000, "1.2M"},
+		{2500000000, "2.5B"},
+		{999999999999999, "999.9T"},
+		{1000000000000000, "1000T"},
+		{-12345, "-12.3k"},
+		{-999, "-999"},
+		{math.MaxInt64, "9223372T"},
+		{math.MinInt64, "-9223372T"},
+	}
+	for _, tt := range tests {
+		if got := compactNumber(tt.n); got != tt.want {
+			t.Errorf("compactNumber(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceFlags(t *testing.T) {
+	tests := []struct {
+		args              []string
+		far, spawn, ahead float64
+		ok                bool
+	}{
+		{nil, defaultFarZ, defaultFarZ - spawnInset, dodgeLookahead, true},
+		{[]string{"-view-distance", "40"}, 40, 40 - spawnInset, dodgeLookahead, true},
+		{[]string{"-view-distance", "40", "-spawn-distance", "30", "-lookahead", "30"}, 40, 30, 30, true},
+		{[]string{"-view-distance", "7"}, 0, 0, 0, false},
+		{[]string{"-view-distance", "81"}, 0, 0, 0, false},
+		{[]string{"-spawn-distance", "20"}, 0, 0, 0, false},
+		{[]string{"-spawn-distance", "0.5"}, 0, 0, 0, false},
+		{[]string{"-lookahead", "0.5"}, 0, 0, 0, false},
+		{[]string{"-spawn-distance", "10", "-lookahead", "12"}, 0, 0, 0, false},
+		{[]string{"-spawn-distance", "10", "-coin-window", "11"}, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		cfg, err := parseConfig(tt.args)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: err = %v, want ok %v", tt.args, err, tt.ok)
+			continue
+		}
+		if tt.ok && (cfg.farZ != tt.far || cfg.spawnZ != tt.spawn || cfg.lookahead != tt.ahead) {
+			t.Errorf("%q: view %v, spawn %v, lookahead %v, want %v, %v, %v", tt.args, cfg.farZ, cfg.spawnZ, cfg.lookahead, tt.far, tt.spawn, tt.ahead)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// --- Authored courses ---
+//
+// -course swaps the random spawner for a script, one spawn per line:
+//
+//	# seconds  what      lane  how
+//	2          obstacle  0     barrier
+//	2          obstacle  1     low
+//	3.5        coins     2     left gold
+//
+// Obstacles are barrier, low or high. Coins come in a line of three, straight
+// unless they step left or right, and can be gold. Lanes count from 0 on the
+// left, times are seconds into the run and go in order. Each spawn comes into
+// view at its time exactly, however the ticks fall.
+
+type courseEvent struct {
+	at    float64
+	line  int // in the script, for the log
+	coins bool
+	lane  int
+	kind  int  // obstacles only
+	step  int  // coins only, lanes moved per coin
+	gold  bool // coins only
+}
+
+var courseKinds = map[string]int{"barrier": kindBarrier, "low": kindLow, "high": kindHigh}
+
+// loadCourse reads and checks a course script, reporting every bad line.
+func loadCourse(path string) ([]courseEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []courseEvent
+	var errs []error
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		ev, err := parseCourseLine(fields)
+		if err == nil && len(events) > 0 && ev.at < events[len(events)-1].at {
+			err = fmt.Errorf("time %v is before line %d's, times go in order", ev.at, events[len(events)-1].line)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %w", path, n, err))
+			continue
+		}
+		ev.line = n
+		events = append(events, ev)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%s: nothing to spawn in it", path)
+	}
+	return events, nil
+}
+
+// parseCourseLine reads one line of a course script, already split up.
+func parseCourseLine(fields []string) (courseEvent, error) {
+	var ev courseEvent
+	if len(fields) < 3 {
+		return ev, errors.New("want a time, obstacle or coins, and a lane")
+	}
+	at, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || !(at >= 0) || math.IsInf(at, 1) {
+		return ev, fmt.Errorf("time must be seconds from 0 up, got %q", fields[0])
+	}
+	lane, err := strconv.Atoi(fields[2])
+	if err != nil || lane < 0 || lane >= numLanes {
+		return ev, fmt.Errorf("lane must be from 0 to %d, got %q", numLanes-1, fields[2])
+	}
+	ev.at, ev.lane = at, lane
+
+	switch fields[1] {
+	case "obstacle":
+		if len(fields) != 4 {
+			return ev, errors.New("an obstacle wants its kind: barrier, low or high")
+		}
+		kind, ok := courseKinds[fields[3]]
+		if !ok {
+			return ev, fmt.Errorf("unknown obstacle kind %q (want barrier, low or high)", fields[3])
+		}
+		ev.kind = kind
+	case "coins":
+		ev.coins = true
+		for _, f := range fields[3:] {
+			switch f {
+			case "straight":
+				ev.step = 0
+			case "left":
+				ev.step = -1
+			case "right":
+				ev.step = 1
+			case "gold":
+				ev.gold = true
+			default:
+				return ev, fmt.Errorf("unknown coin option %q (want straight, left, right or gold)", f)
+			}
+		}
+	default:
+		return ev, fmt.Errorf("unknown spawn %q (want obstacle or coins)", fields[1])
+	}
+	return ev, nil
+}
+
+// spawnScripted puts every course spawn that's come due on the track. One
+// that came due partway through the tick starts as far in as it would have
+// got by now. The object cap doesn't apply, the script's author decides how
+// busy it gets, but a spawn the pools have no room for is dropped.
+func (g *game) spawnScripted() {
+	for ; g.courseNext < len(g.cfg.script); g.courseNext++ {
+		ev := g.cfg.script[g.courseNext]
+		if ev.at > g.elapsed {
+			return
+		}
+		z := g.cfg.spawnZ - (g.elapsed-ev.at)*g.speed
+		if ev.coins {
+			g.scriptCoins(ev, z)
+		} else {
+			g.scriptObstacle(ev, z)
+		}
+	}
+}
+
+func (g *game) scriptObstacle(ev courseEvent, z float64) {
+	for i := range g.obstacles {
+		if !g.obstacles[i].active {
+			g.obstacles[i] = obstacle{lane: ev.lane, kind: ev.kind, z: z, active: true}
+			g.log.Info("spawn.obstacle", "t", g.elapsed, "lane", ev.lane, "kind", ev.kind, "z", z, "line", ev.line)
+			return
+		}
+	}
+	g.log.Info("course.dropped", "t", g.elapsed, "line", ev.line)
+}
+
+func (g *game) scriptCoins(ev courseEvent, z float64) {
+	lanes := coinLanes(ev.lane, ev.step)
+	g.log.Info("spawn.coins", "t", g.elapsed, "lane", ev.lane, "step", ev.step, "gold", ev.gold, "z", z, "line", ev.line)
+	j := 0
+	for i := range g.coinPool {
+		if j < coinsPerLine && !g.coinPool[i].active {
+			g.coinPool[i] = coinObj{
+				lane:   lanes[j],
+				x:      float64(lanes[j]),
+				gold:   ev.gold,
+				z:      z + float64(j)*g.cfg.coinSpacing,
+				active: true,
+			}
+			j++
+		}
+	}
+	if j < coinsPerLine {
+		g.log.Info("course.dropped", "t", g.elapsed, "line", ev.line, "coins", coinsPerLine-j)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeCourse writes script to a course file and returns its path.
+func writeCourse(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "course.txt")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCourse(t *testing.T) {
+	path := writeCourse(t, `# a warm-up
+1    obstacle 0 barrier
+1    obstacle 2 high  # two at once
+2.5  coins    1 left gold
+3    coins    0
+`)
+	events, err := loadCourse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []courseEvent{
+		{at: 1, line: 2, lane: 0, kind: kindBarrier},
+		{at: 1, line: 3, lane: 2, kind: kindHigh},
+		{at: 2.5, line: 4, coins: true, lane: 1, step: -1, gold: true},
+		{at: 3, line: 5, coins: true, lane: 0},
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("got %+v, want %+v", events, want)
+	}
+
+	// Every bad line gets reported, by line
+	path = writeCourse(t, `1 obstacle 0 barrier
+x obstacle 0 low
+2 obstacle 3 low
+2 obstacle 1 tall
+2 coins 1 sideways
+2 train 1
+0.5 obstacle 0 low
+2 coins
+`)
+	_, err = loadCourse(path)
+	if err == nil {
+		t.Fatal("a broken course loaded")
+	}
+	for _, line := range []string{":2: time", ":3: lane", ":4: unknown obstacle kind", ":5: unknown coin option", ":6: unknown spawn", ":7: time 0.5 is before line 1's", ":8: want a time"} {
+		if !strings.Contains(err.Error(), path+line) {
+			t.Errorf("no %q in\n%v", path+line, err)
+		}
+	}
+	if strings.Contains(err.Error(), path+":1:") {
+		t.Errorf("the good line got reported too:\n%v", err)
+	}
+
+	if _, err := loadCourse(writeCourse(t, "# nothing but this\n")); err == nil {
+		t.Error("an empty course loaded")
+	}
+}
+
+func TestCourseSpawns(t *testing.T) {
+	path := writeCourse(t, "1 obstacle 1 low\n2 coins 0 right\n")
+	for _, dt := range []float64{0.05, 0.07, 0.1} {
+		g := testGame(t, 80, 24, "-course", path)
+		buf := logEvents(g, "t", "z")
+		draws := g.src.draws
+		for g.elapsed < 3 {
+			spawned := g.courseNext
+			g.update(dt)
+			// However the ticks fell, the obstacle starts where it would be
+			// had it spawned at 1s on the dot
+			if spawned == 0 && g.courseNext > 0 {
+				for _, o := range g.obstacles {
+					if want := g.cfg.spawnZ - (g.elapsed-1)*g.speed; o.active && o.z != want {
+						t.Errorf("dt %v: obstacle spawned at z %v, want %v", dt, o.z, want)
+					}
+				}
+			}
+		}
+		got := eventLines(buf, "spawn.obstacle", "spawn.coins", "spawn.pattern")
+		want := []string{
+			"msg=spawn.obstacle lane=1 kind=1 line=1",
+			"msg=spawn.coins lane=0 step=1 gold=false line=2",
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("dt %v: spawned\n%s\nwant\n%s", dt, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+		if g.src.draws != draws {
+			t.Errorf("dt %v: the course drew %d times from the RNG", dt, g.src.draws-draws)
+		}
+	}
+}
+
+func TestCourseResume(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := writeCourse(t, "1 obstacle 1 low\n2 coins 0 right\n4 obstacle 2 high\n")
+	g := testGame(t, 80, 24, "-course", path)
+	for range 50 {
+		g.update(0.05)
+	}
+	if err := g.save(); err != nil {
+		t.Fatal(err)
+	}
+	sv, cfg, err := loadSave()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newGame(80, 24, cfg)
+	h.countdown = 0
+	sv.restore(h)
+	if h.courseNext != g.courseNext {
+		t.Fatalf("resumed at spawn %d of the course, saved at %d", h.courseNext, g.courseNext)
+	}
+	for range 60 {
+		g.update(0.05)
+		h.update(0.05)
+	}
+	if h.obstacles != g.obstacles || h.coinPool != g.coinPool {
+		t.Error("the resumed course went its own way")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "math"
+
+// --- Lane easing ---
+//
+// A lane change takes the same time whatever -lane-easing says, laneSpeed
+// lanes a second, but the easing decides how the runner gets there: linear
+// slides across at an even pace, ease-in-out gets going and settles gently,
+// and overshoot swings a bit past the lane and springs back into it.
+
+// Lane easings
+const (
+	easeLinear    = "linear"
+	easeInOut     = "ease-in-out"
+	easeOvershoot = "overshoot"
+)
+
+const (
+	laneSpeed     = 8.0     // lanes a second a lane change covers
+	overshootPull = 1.70158 // how far overshoot swings past, about 10%
+)
+
+// easings map a lane change's progress, 0 to 1, to how far across the runner
+// is. Every one starts at 0 and ends at exactly 1.
+var easings = map[string]func(t float64) float64{
+	easeLinear: func(t float64) float64 { return t },
+	easeInOut: func(t float64) float64 {
+		return (1 - math.Cos(t*math.Pi)) / 2
+	},
+	easeOvershoot: func(t float64) float64 {
+		t--
+		return 1 + (overshootPull+1)*t*t*t + overshootPull*t*t
+	},
+}
+
+// moveLane carries a lane change on by dt. The runner only counts as in the
+// new lane once it's done.
+func (g *game) moveLane(dt float64) {
+	target := float64(g.targetLane)
+	if g.laneX == target && g.runnerLane == g.targetLane {
+		return
+	}
+	span := target - g.laneFrom
+	if span == 0 {
+		g.laneT = 1
+	} else {
+		g.laneT = math.Min(g.laneT+dt*laneSpeed/math.Abs(span), 1)
+	}
+	g.laneX = g.laneFrom + span*easings[g.cfg.laneEasing](g.laneT)
+	if g.laneT >= 1 {
+		g.laneX = target
+		g.runnerLane = g.targetLane
+	}
+}
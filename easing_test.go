@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEasingEnds(t *testing.T) {
+	for name, ease := range easings {
+		// Ending off by a rounding error would leave the runner a hair out
+		// of lane, starting off by one doesn't show
+		if math.Abs(ease(0)) > 1e-12 || ease(1) != 1 {
+			t.Errorf("%s: runs from %v to %v, want 0 to 1", name, ease(0), ease(1))
+		}
+	}
+}
+
+func TestLaneChangeTime(t *testing.T) {
+	tests := []struct {
+		easing   string
+		from, to int
+		swings   bool // whether it goes past the lane on the way
+	}{
+		{easeLinear, 1, 0, false},
+		{easeLinear, 0, 2, false},
+		{easeInOut, 1, 2, false},
+		{easeInOut, 2, 0, false},
+		{easeOvershoot, 1, 0, true},
+		{easeOvershoot, 0, 2, true},
+	}
+	const dt = 0.001
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual", "-lane-easing", tt.easing)
+		g.runnerLane, g.targetLane, g.laneX = tt.from, tt.from, float64(tt.from)
+		g.steer(tt.to)
+
+		lanes := math.Abs(float64(tt.to - tt.from))
+		want := lanes / laneSpeed
+		took, past := 0.0, 0.0
+		for g.runnerLane != tt.to && took < 1 {
+			g.moveLane(dt)
+			took += dt
+			// How far beyond the new lane, or behind the old one, it's got
+			dir := float64(tt.to - tt.from)
+			past = math.Max(past, (g.laneX-float64(tt.to))*dir/lanes)
+			past = math.Max(past, (float64(tt.from)-g.laneX)*dir/lanes)
+		}
+		if math.Abs(took-want) > 2*dt {
+			t.Errorf("%s %d to %d: took %.3fs, want %.3fs", tt.easing, tt.from, tt.to, took, want)
+		}
+		if g.laneX != float64(tt.to) {
+			t.Errorf("%s %d to %d: ended at x %v", tt.easing, tt.from, tt.to, g.laneX)
+		}
+		if swung := past > 1e-9; swung != tt.swings {
+			t.Errorf("%s %d to %d: went %.3f past the lanes, want swinging %v", tt.easing, tt.from, tt.to, past, tt.swings)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Framebuffer ---
+//
+// A framebuffer is a pretend terminal: write what the game sends a terminal
+// to it and it keeps the screen as a grid of cells, so a frame can be checked
+// cell by cell ("a coin at row 10, column 40") rather than as a wall of
+// escapes. It's a terminal's out with the fds set to -1.
+//
+// It only understands what the game sends: moving the cursor, clearing the
+// screen or the rest of it, SGR colours and faint, the modes the game turns
+// on and off (which it ignores), \r, \n and UTF-8 text. Anything else is an
+// error, kept for err rather than returned, so writes carry on. Autowrap's
+// taken to be off the way the game sets it, so text past the last column is
+// dropped.
+
+// fbCell is one cell of a framebuffer: a character and how it's drawn.
+type fbCell struct {
+	ch     rune
+	fg, bg int // SGR colours, 0 for the terminal's own
+	faint  bool
+}
+
+type framebuffer struct {
+	w, h     int
+	cells    []fbCell
+	row, col int    // cursor, from 0
+	pen      fbCell // colours text is written in
+	pending  []byte // an escape or UTF-8 rune cut off at the end of a write
+	err      error  // the first thing that couldn't be decoded
+}
+
+func newFramebuffer(w, h int) *framebuffer {
+	fb := &framebuffer{w: w, h: h, cells: make([]fbCell, w*h)}
+	fb.clear(0)
+	return fb
+}
+
+// Write decodes p onto the screen. It always takes all of p.
+func (fb *framebuffer) Write(p []byte) (int, error) {
+	b := append(fb.pending, p...)
+	fb.pending = nil
+	for len(b) > 0 {
+		n := fb.decode(b)
+		if n == 0 {
+			fb.pending = append([]byte(nil), b...)
+			break
+		}
+		b = b[n:]
+	}
+	return len(p), nil
+}
+
+// decode takes one character, control or escape off the front of b and
+// returns how many bytes it was, or 0 if b stops partway through one.
+func (fb *framebuffer) decode(b []byte) int {
+	switch b[0] {
+	case '\r':
+		fb.col = 0
+		return 1
+	case '\n':
+		fb.row = min(fb.row+1, fb.h-1)
+		return 1
+	case '\033':
+		return fb.escape(b)
+	}
+	if !utf8.FullRune(b) {
+		return 0
+	}
+	r, n := utf8.DecodeRune(b)
+	if r == utf8.RuneError || r < ' ' {
+		fb.fail("can't draw %q", b[:n])
+	}
+	if fb.col < fb.w && fb.row < fb.h {
+		c := fb.pen
+		c.ch = r
+		fb.cells[fb.row*fb.w+fb.col] = c
+	}
+	fb.col++
+	return n
+}
+
+// escape carries out the CSI sequence at the front of b.
+func (fb *framebuffer) escape(b []byte) int {
+	if len(b) < 2 {
+		return 0
+	}
+	if b[1] != '[' {
+		fb.fail("can't decode escape %q", b[:2])
+		return 2
+	}
+	end := 2
+	for end < len(b) && (b[end] < '@' || b[end] > '~') {
+		end++
+	}
+	if end == len(b) {
+		return 0
+	}
+	params, final := string(b[2:end]), b[end]
+	if strings.HasPrefix(params, "?") {
+		// Modes: alt screen, cursor, autowrap, none of which change cells
+		if final != 'h' && final != 'l' {
+			fb.fail("can't decode escape %q", b[:end+1])
+		}
+		return end + 1
+	}
+	nums, ok := csiParams(params)
+	if !ok {
+		fb.fail("can't decode escape %q", b[:end+1])
+		return end + 1
+	}
+	switch final {
+	case 'H':
+		row, col := 1, 1
+		if len(nums) > 0 && nums[0] > 0 {
+			row = nums[0]
+		}
+		if len(nums) > 1 && nums[1] > 0 {
+			col = nums[1]
+		}
+		fb.row, fb.col = min(row, fb.h)-1, min(col, fb.w)-1
+	case 'J':
+		switch {
+		case len(nums) == 0 || nums[0] == 0:
+			fb.clear(min(fb.row*fb.w+fb.col, len(fb.cells)))
+		case nums[0] == 2:
+			fb.clear(0)
+		default:
+			fb.fail("can't decode escape %q", b[:end+1])
+		}
+	case 'm':
+		fb.sgr(nums)
+	default:
+		fb.fail("can't decode escape %q", b[:end+1])
+	}
+	return end + 1
+}
+
+// csiParams splits a CSI sequence's numbers, missing ones as 0.
+func csiParams(s string) ([]int, bool) {
+	if s == "" {
+		return nil, true
+	}
+	var nums []int
+	for _, f := range strings.Split(s, ";") {
+		n := 0
+		if f != "" {
+			var err error
+			if n, err = strconv.Atoi(f); err != nil || n < 0 {
+				return nil, false
+			}
+		}
+		nums = append(nums, n)
+	}
+	return nums, true
+}
+
+// sgr sets the pen from an SGR sequence's numbers.
+func (fb *framebuffer) sgr(nums []int) {
+	if len(nums) == 0 {
+		nums = []int{0}
+	}
+	for _, n := range nums {
+		switch {
+		case n == 0:
+			fb.pen = fbCell{}
+		case n == 2:
+			fb.pen.faint = true
+		case n == 22:
+			fb.pen.faint = false
+		case n == 39:
+			fb.pen.fg = 0
+		case n == 49:
+			fb.pen.bg = 0
+		case n >= 30 && n <= 37, n >= 90 && n <= 97:
+			fb.pen.fg = n
+		case n >= 40 && n <= 47, n >= 100 && n <= 107:
+			fb.pen.bg = n
+		default:
+			fb.fail("can't decode SGR %d", n)
+		}
+	}
+}
+
+// clear blanks every cell from the i'th on.
+func (fb *framebuffer) clear(i int) {
+	for ; i < len(fb.cells); i++ {
+		fb.cells[i] = fbCell{ch: ' '}
+	}
+}
+
+func (fb *framebuffer) fail(format string, args ...any) {
+	if fb.err == nil {
+		fb.err = fmt.Errorf(format, args...)
+	}
+}
+
+// cell is what's at row and col, counting from 0.
+func (fb *framebuffer) cell(row, col int) fbCell {
+	return fb.cells[row*fb.w+col]
+}
+
+// line is a row's characters, colours left out.
+func (fb *framebuffer) line(row int) string {
+	var sb strings.Builder
+	for _, c := range fb.cells[row*fb.w : (row+1)*fb.w] {
+		sb.WriteRune(c.ch)
+	}
+	return sb.String()
+}
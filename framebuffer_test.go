@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFramebufferDecode(t *testing.T) {
+	type at struct {
+		row, col int
+		cell     fbCell
+	}
+	plain := func(r rune) fbCell { return fbCell{ch: r} }
+	tests := []struct {
+		name  string
+		in    string
+		lines []string // the first rows, if any
+		cells []at
+	}{
+		{"text", "abc", []string{"abc  ", "     "}, nil},
+		{"cursor", "\033[2;3Hxy", []string{"     ", "  xy "}, nil},
+		{"home", "\033[2;2Ha\033[Hb", []string{"b    ", " a   "}, nil},
+		{"past the edge", "\033[1;4Habcdef", []string{"   ab"}, nil},
+		{"past the bottom", "\033[9;9Hz", []string{"     ", "     ", "    z"}, nil},
+		{"newlines", "ab\r\ncd\ne", []string{"ab   ", "cd   ", "  e  "}, nil},
+		{"clear", "abc\r\nde\033[2J", []string{"     ", "     "}, nil},
+		{"clear the rest", "abcde\r\nfghij\r\nklmno\033[2;3H\033[J", []string{"abcde", "fg   ", "     "}, nil},
+		{"modes", "\033[?1049h\033[?25l\033[?7lok\033[?7h", []string{"ok   "}, nil},
+		{"UTF-8", "▀▄█", []string{"▀▄█  "}, nil},
+		{"colours", "\033[31;44mA\033[0mB\033[93;39;101mC", nil, []at{
+			{0, 0, fbCell{ch: 'A', fg: 31, bg: 44}},
+			{0, 1, plain('B')},
+			{0, 2, fbCell{ch: 'C', bg: 101}},
+		}},
+		{"faint", "\033[0;2mA\033[22mB\033[2;34mC\033[mD", nil, []at{
+			{0, 0, fbCell{ch: 'A', faint: true}},
+			{0, 1, plain('B')},
+			{0, 2, fbCell{ch: 'C', fg: 34, faint: true}},
+			{0, 3, plain('D')},
+		}},
+	}
+	for _, tt := range tests {
+		// The same whole and a byte at a time, the way a terminal might get
+		// it cut up
+		for _, split := range []bool{false, true} {
+			fb := newFramebuffer(5, 3)
+			if split {
+				for i := range len(tt.in) {
+					fb.Write([]byte{tt.in[i]})
+				}
+			} else {
+				fb.Write([]byte(tt.in))
+			}
+			if fb.err != nil {
+				t.Errorf("%s, split %v: %v", tt.name, split, fb.err)
+			}
+			for r, want := range tt.lines {
+				if got := fb.line(r); got != want {
+					t.Errorf("%s, split %v: row %d is %q, want %q", tt.name, split, r, got, want)
+				}
+			}
+			for _, c := range tt.cells {
+				if got := fb.cell(c.row, c.col); got != c.cell {
+					t.Errorf("%s, split %v: %d,%d is %+v, want %+v", tt.name, split, c.row, c.col, got, c.cell)
+				}
+			}
+		}
+	}
+}
+
+func TestFramebufferErrors(t *testing.T) {
+	for _, in := range []string{
+		"\033]0;title\a", // not CSI
+		"\033[5A",        // cursor up, which the game never sends
+		"\033[2K",        // nor clearing a line
+		"\033[38;5;1m",   // 256 colours
+		"\033[1;xH",      // not a number
+		"\033[?25x",      // a mode that's neither set nor reset
+		"\033[1J",        // clearing up to the cursor
+		"a\tb",           // control characters
+		"\xff",           // not UTF-8
+	} {
+		fb := newFramebuffer(20, 2)
+		fb.Write([]byte(in + "\033[2;1Hafter"))
+		if fb.err == nil {
+			t.Errorf("%q: decoded", in)
+		}
+		// Decoding carries on past it
+		if got := fb.line(1); !strings.HasPrefix(got, "after") {
+			t.Errorf("%q: stopped decoding, row 1 is %q", in, got)
+		}
+	}
+
+	// A sequence cut off at the end of a write is finished by the next
+	fb := newFramebuffer(5, 1)
+	fb.Write([]byte("\033[1;"))
+	fb.Write([]byte("3Hx\xe2\x96"))
+	if got := fb.line(0); got != "  x  " || len(fb.pending) == 0 {
+		t.Errorf("cut off: %q, pending %q", got, fb.pending)
+	}
+	fb.Write([]byte("\x80"))
+	if got := fb.line(0); got != "  x▀ " || fb.err != nil {
+		t.Errorf("finished: %q, %v", got, fb.err)
+	}
+}
+
+func TestFramebufferRoundTrip(t *testing.T) {
+	// Frame after frame, each only sending the rows that changed, comes out
+	// as the screen the game drew. Half-block cells are checked by
+	// -debug-render, which does this every frame
+	for _, args := range [][]string{nil, {"-zen"}, {"-no-altscreen"}, {"-view", "topdown"}, {"-hud", "bottom"}} {
+		g := testGame(t, 80, 24, args...)
+		fb := newFramebuffer(g.width, g.top+g.shownRows())
+		for tick := range 200 {
+			g.update(0.05)
+			fb.Write(g.render())
+			if fb.err != nil {
+				t.Fatalf("%q, tick %d: %v", args, tick, fb.err)
+			}
+			for r := range g.shownRows() {
+				if got, want := fb.line(g.top+r), string(g.screen[r*g.width:(r+1)*g.width]); got != want {
+					t.Fatalf("%q, tick %d, row %d:\n got %q\nwant %q", args, tick, r, got, want)
+				}
+			}
+		}
+		// Zen's text is all faint
+		if g.cfg.zen && !fb.cell(g.top+g.shownRows()-1, 0).faint {
+			t.Errorf("%q: text isn't faint", args)
+		}
+	}
+}
+
+func TestFramebufferCells(t *testing.T) {
+	g := testGame(t, 80, 24, "-manual", "-mode", "practice")
+	for range 100 {
+		g.update(0.05) // past the opening banner
+	}
+	clearTrack(g)
+	g.curve, g.score, g.coins = 0, 1234, 56
+
+	// Halfway down the track, the middle of the ground rows below the
+	// horizon at row 8: row 8 + 16/2. The middle lane's the middle column
+	g.coinPool[0] = coinObj{lane: 1, x: 1, z: g.cfg.farZ / 2, gold: true, active: true}
+	fb := newFramebuffer(g.width, g.height)
+	g.redraw = true
+	fb.Write(g.render())
+	if fb.err != nil {
+		t.Fatal(fb.err)
+	}
+	if c := fb.cell(16, 40); c.ch != '@' {
+		t.Errorf("gold coin at row 16, column 40 is %q, row is %q", c.ch, fb.line(16))
+	}
+
+	// The HUD's in the top right corner
+	for row, want := range []string{" SCORE: 0001234  ", " COINS: 56  "} {
+		if got := fb.line(row); !strings.HasSuffix(got, want) {
+			t.Errorf("HUD row %d is %q, want it to end %q", row, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+// --- Gaps ---
+//
+// Every so often the ground drops away right across the track, and the only
+// way over is to jump. Land short, or don't jump at all, and the runner falls
+// in, which is a crash like any other. A gap's gapShare of how far a jump goes
+// at the speed it comes in at, so there's some room either side to take off
+// in, and the track's kept clear for a stretch before and after it so there's
+// nothing else to deal with on the way in or out.
+
+const (
+	gapShare     = 0.4 // share of a jump's length a gap spans
+	gapClearSecs = 1.5 // seconds of track kept free of obstacles either side of a gap
+)
+
+// gap is a stretch of track with no ground. Like a closure, its start and end
+// travel toward the viewer.
+type gap struct {
+	start, end float64
+	active     bool
+}
+
+// gapClear is how much track is kept clear either side of a gap at the
+// current speed.
+func (g *game) gapClear() float64 {
+	return g.speed * gapClearSecs
+}
+
+// startGap opens a gap at the horizon, if nothing's close enough behind it to
+// crowd the jump, and reports whether it did. It draws nothing from rng.
+func (g *game) startGap() bool {
+	for i := range g.obstacles {
+		if g.obstacles[i].active && g.obstacles[i].z > g.cfg.spawnZ-g.gapClear() {
+			return false
+		}
+	}
+	length := g.speed * g.cfg.jumpSecs * gapShare
+	g.gap = gap{
+		start:  g.cfg.spawnZ,
+		end:    g.cfg.spawnZ + length,
+		active: true,
+	}
+	g.log.Info("gap", "t", g.elapsed, "length", length)
+	return true
+}
+
+// gapComingIn reports whether a gap's still too close to the horizon for
+// obstacles to spawn behind it.
+func (g *game) gapComingIn() bool {
+	return g.gap.active && g.gap.end > g.cfg.spawnZ-g.gapClear()
+}
+
+// updateGap moves the gap toward the viewer and drops the runner in if
+// they're over it on the ground. Falling in fills it, so it costs one crash,
+// not one a tick. A run something else has just ended is left as it is.
+func (g *game) updateGap(dt float64) {
+	p := &g.gap
+	if !p.active || g.over {
+		return
+	}
+	p.start -= g.speed * dt
+	p.end -= g.speed * dt
+	if p.end < -1 {
+		p.active = false
+		return
+	}
+	if p.start > runnerZ || p.end <= runnerZ || g.airborne() {
+		return
+	}
+	p.active = false
+	g.log.Info("fall", "t", g.elapsed, "lane", g.runnerLane)
+	g.crash()
+}
+
+// gapJump reports whether it's time to jump a gap: the runner's on the ground
+// and the near edge is close enough that a jump now lands past the far one,
+// with the slack split evenly either side.
+func (g *game) gapJump() bool {
+	p := g.gap
+	if !p.active || p.start < runnerZ || g.airborne() || g.sliding() {
+		return false
+	}
+	lead := (g.speed*g.cfg.jumpSecs - (p.end - p.start)) / 2
+	return p.start-runnerZ <= lead
+}
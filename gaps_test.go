@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGapClearOrFall(t *testing.T) {
+	// A gap's gapShare of a jump long, so a jump that takes off with the near
+	// edge anywhere from a jump's length less the gap away to right on the
+	// runner clears it. lead is in jumps, -1 for not jumping at all.
+	tests := []struct {
+		name string
+		lead float64
+		fall bool
+	}{
+		{"on time", (1 - gapShare) / 2, false},
+		{"right at the edge", 0.02, false},
+		{"early but long enough", 1 - gapShare - 0.02, false},
+		{"too early, lands in it", 1 - gapShare + 0.1, true},
+		{"too late, already in", -0.05, true},
+		{"never jumps", -1, true},
+	}
+	const dt = 0.005
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual", "-mode", "practice")
+		clearTrack(g)
+		g.spawnTimer, g.coinTimer = -100, -100
+		buf := logEvents(g, "t")
+		jumpLen := g.speed * g.cfg.jumpSecs
+		g.gap = gap{start: runnerZ + jumpLen, end: runnerZ + jumpLen*(1+gapShare), active: true}
+		jumped := false
+		for range int(3 * g.cfg.jumpSecs / dt) {
+			if !jumped && tt.lead != -1 && g.gap.start-runnerZ <= tt.lead*jumpLen {
+				g.jump()
+				jumped = true
+			}
+			g.update(dt)
+		}
+		if fell := len(eventLines(buf, "fall")) > 0; fell != tt.fall {
+			t.Errorf("%s: fell %v, want %v", tt.name, fell, tt.fall)
+		}
+		if n := len(eventLines(buf, "crash")); n > 1 {
+			t.Errorf("%s: %d crashes for one gap", tt.name, n)
+		}
+	}
+}
+
+func TestGapJumpTiming(t *testing.T) {
+	g := testGame(t, 80, 24, "-manual")
+	clearTrack(g)
+	jumpLen := g.speed * g.cfg.jumpSecs
+	lead := jumpLen * (1 - gapShare) / 2
+	tests := []struct {
+		start    float64 // near edge, in front of the runner
+		airborne bool
+		want     bool
+	}{
+		{lead + 0.1, false, false},
+		{lead - 0.1, false, true},
+		{0.1, false, true},
+		{lead - 0.1, true, false},
+		{-0.1, false, false}, // already going under
+	}
+	for _, tt := range tests {
+		g.gap = gap{start: runnerZ + tt.start, end: runnerZ + tt.start + jumpLen*gapShare, active: true}
+		g.jumpT = 0
+		if tt.airborne {
+			g.jumpT = 0.1
+		}
+		if got := g.gapJump(); got != tt.want {
+			t.Errorf("edge %.2f ahead, airborne %v: gapJump = %v, want %v", tt.start, tt.airborne, got, tt.want)
+		}
+	}
+}
+
+func TestStartGapWaitsForClearTrack(t *testing.T) {
+	g := testGame(t, 80, 24, "-manual")
+	clearTrack(g)
+	g.obstacles[0] = obstacle{lane: 1, kind: kindBarrier, z: g.cfg.spawnZ - g.gapClear()/2, active: true}
+	if g.startGap() || g.gap.active {
+		t.Error("opened a gap with an obstacle just behind the horizon")
+	}
+	g.obstacles[0].z = g.cfg.spawnZ - g.gapClear()*2
+	if !g.startGap() || !g.gap.active {
+		t.Fatal("didn't open a gap on a clear track")
+	}
+	if want := g.speed * g.cfg.jumpSecs * gapShare; math.Abs(g.gap.end-g.gap.start-want) > 1e-9 {
+		t.Errorf("gap %v long, want %v", g.gap.end-g.gap.start, want)
+	}
+	if !g.gapComingIn() {
+		t.Error("a gap at the horizon isn't holding back spawns")
+	}
+}
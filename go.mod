@@ -2,7 +2,6 @@ module github.com/0xdeafcafe/subway-surfer
 
 go 1.25.1
 
-require (
-	golang.org/x/sys v0.41.0 // indirect
-	golang.org/x/term v0.40.0 // indirect
-)
+require golang.org/x/term v0.40.0
+
+require golang.org/x/sys v0.41.0 // indirect
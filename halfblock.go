@@ -0,0 +1,279 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// --- Half-block rendering ---
+//
+// -halfblock draws the ground of the perspective view at twice the rows: the
+// track and everything on it go into pixels, two to a cell, and each cell
+// comes out as a ▀ or ▄ with its top and bottom coloured separately. The rest
+// of the screen is the same text as ever, the runner included.
+//
+// Rows stay one byte a column all the way through, so diffing, clipping and
+// shaking don't change. A cell of pixels is a byte from halfCell, and only
+// turns into colours and a glyph on its way out to the terminal.
+
+// Pixel colours, up to 8 so a cell's pair fits in a byte
+const (
+	pxNone = iota // nothing, whatever the cell had underneath shows
+	pxRail
+	pxTie
+	pxBarrier
+	pxLow
+	pxHigh
+	pxCoin
+	pxFire
+)
+
+// obstaclePx is the colour each obstacle kind's drawn in.
+var obstaclePx = [...]byte{kindBarrier: pxBarrier, kindLow: pxLow, kindHigh: pxHigh}
+
+// pxSGR is the foreground colour each pixel's drawn in, add 10 for background.
+var pxSGR = [...]int{pxRail: 37, pxTie: 33, pxBarrier: 31, pxLow: 35, pxHigh: 36, pxCoin: 93, pxFire: 91}
+
+// halfCell packs a cell's top and bottom pixels into one byte, above plain
+// ASCII so it can't be mistaken for text.
+func halfCell(top, bottom byte) byte {
+	return 0x80 | top<<3 | bottom
+}
+
+// isHalfCell reports whether c is a byte from halfCell.
+func isHalfCell(c byte) bool {
+	return c >= 0x80 && c < 0xc0
+}
+
+// drawGroundHalf is drawGround for -halfblock: the ground row's two pixel rows,
+// with the runner drawn over them as text.
+func (g *game) drawGroundHalf(buf []byte, row, horizon int) {
+	if g.height-horizon <= 0 || len(buf) == 0 || row <= horizon {
+		return
+	}
+	if cap(g.pxTop) < len(buf) {
+		g.pxTop, g.pxBottom = make([]byte, len(buf)), make([]byte, len(buf))
+	}
+	top, bottom := g.pxTop[:len(buf)], g.pxBottom[:len(buf)]
+	l1, r1 := g.rasterRow(top, 2*row, 2*horizon, 2*g.height)
+	l2, r2 := g.rasterRow(bottom, 2*row+1, 2*horizon, 2*g.height)
+
+	// Ground texture outside the track, bare track inside it
+	g.fillGround(buf, row)
+	for x := max(min(l1, l2), 0); x <= min(max(r1, r2), len(buf)-1); x++ {
+		buf[x] = ' '
+	}
+	for x := range buf {
+		if top[x] != pxNone || bottom[x] != pxNone {
+			buf[x] = halfCell(top[x], bottom[x])
+		}
+	}
+
+	g.drawTimings(buf, row, horizon)
+	g.drawRunner(buf, row, horizon)
+}
+
+// rasterRow draws pixel row y of the ground into px, on a screen height pixel
+// rows tall with the horizon at pixel row horizon. It's drawGround over again
+// at twice the rows, and returns where the track's edges fell, on screen or
+// not.
+func (g *game) rasterRow(px []byte, y, horizon, height int) (left, right int) {
+	for i := range px {
+		px[i] = pxNone
+	}
+	span := height - horizon
+	depth := float64(y-horizon) / float64(span)
+	zRow := func(z float64) int {
+		return horizon + int((1.0-z/g.cfg.farZ)*float64(span))
+	}
+	set := func(x int, c byte) {
+		if x >= 0 && x < len(px) {
+			px[x] = c
+		}
+	}
+
+	fullTw := float64(g.trackCols())
+	tw := max(int(fullTw*depth), 3)
+	center := g.width/2 + g.curveShift((1-depth)*g.cfg.farZ)
+	left, right = center-tw/2, center+tw/2
+
+	// As in drawGround, left and right are the real rails, maybe off screen,
+	// and only the inside of the track between in0 and in1 gets drawn on
+	in0, in1 := max(left+1, 0), min(right, len(px))
+	set(left, pxRail)
+	set(right, pxRail)
+
+	// Lane dividers, coin coloured inside a bonus zone
+	zoneTop, zoneBottom := zRow(g.zone.end), zRow(g.zone.start)
+	divider := byte(pxTie)
+	if g.zone.active && y >= zoneTop && y <= zoneBottom {
+		divider = pxCoin
+	}
+	lw := float64(tw) / float64(numLanes)
+	if (int(g.scrollOff*4)+y)%6 >= 2 {
+		for l := 1; l < numLanes; l++ {
+			if dx := left + int(float64(l)*lw); dx >= in0 && dx < in1 {
+				set(dx, divider)
+			}
+		}
+	}
+
+	// Cross-ties, dotted in the middle lane
+	if int(float64(y)+g.scrollOff*6)%8 == 0 {
+		for x := in0; x < in1; x++ {
+			if lane := min(int(float64(x-left)/lw), numLanes-1); px[x] == pxNone && (lane != 1 || x%2 == 0) {
+				px[x] = pxTie
+			}
+		}
+	}
+
+	// Bonus zone start and end lines
+	if g.zone.active && ((g.zone.start >= 0 && y == zoneBottom) || (g.zone.end <= g.cfg.farZ && y == zoneTop)) {
+		for x := in0; x < in1; x++ {
+			px[x] = pxCoin
+		}
+	}
+
+	// Fire down a closed lane, flickering yellow
+	if c := g.closure; c.active && y >= zRow(min(c.end, g.cfg.farZ)) && y <= zRow(max(c.start, 0)) {
+		for x := max(left+int(float64(c.lane)*lw)+1, in0); x < min(left+int(float64(c.lane+1)*lw), in1); x++ {
+			px[x] = pxFire
+			if g.fireGlyph(x, y) == '*' {
+				px[x] = pxCoin
+			}
+		}
+	}
+
+	// Nothing at all across a gap, rails included
+	if p := g.gap; p.active && y >= zRow(min(p.end, g.cfg.farZ)) && y <= zRow(max(p.start, 0)) {
+		for x := max(left, 0); x <= min(right, len(px)-1); x++ {
+			px[x] = pxNone
+		}
+	}
+
+	// Checkered finish line
+	if g.cfg.levelLength > 0 {
+		finishZ := g.cfg.levelLength - g.distance
+		if finishZ >= 0 && finishZ <= g.cfg.farZ {
+			if fy := zRow(finishZ); y >= fy-3 && y <= fy {
+				for x := in0; x < in1; x++ {
+					px[x] = pxNone
+					if (x+y/2)%2 == 0 {
+						px[x] = pxRail
+					}
+				}
+			}
+		}
+	}
+
+	// Obstacles, the same shapes as in text but solid
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		if !obs.active || obs.z < 0.5 || obs.z > g.cfg.farZ {
+			continue
+		}
+		pop := g.obstaclePop(obs)
+		obsRow := zRow(obs.z)
+		obsTop := max(obsRow-int(math.Round(4*pop)), horizon+2) // the first pixel row drawn
+		obsTw := g.obstacleCols(obs.z)
+		if y < obsTop || y > obsRow {
+			continue
+		}
+		if obsTw < minObstacleCols {
+			// Too far off to have a shape, a speck in its lane
+			if y == obsRow {
+				set(g.width/2+g.curveShift(obs.z)-minObstacleCols/2+obs.lane*minObstacleCols/numLanes, obstaclePx[obs.kind])
+			}
+			continue
+		}
+		obsLeft := g.width/2 + g.curveShift(obs.z) - obsTw/2
+		obsLW := float64(obsTw) / float64(numLanes)
+		obsW := obsLW * 0.7 * pop
+		ox := obsLeft + int(float64(obs.lane)*obsLW+(obsLW-obsW)/2)
+		ow := max(int(obsW), 1)
+		hollow := !g.threatens(obs)
+		for x := ox; x < ox+ow; x++ {
+			edge := x == ox || x == ox+ow-1
+			switch obs.kind {
+			case kindBarrier:
+				if !hollow || y == obsTop || y == obsRow || edge {
+					set(x, pxBarrier)
+				}
+			case kindLow:
+				if y >= obsRow-1 {
+					set(x, pxLow)
+				}
+			case kindHigh:
+				if y <= obsTop+1 || edge {
+					set(x, pxHigh)
+				}
+			}
+		}
+	}
+
+	// Coins, gold ones a pixel taller
+	for i := range g.coinPool {
+		cn := &g.coinPool[i]
+		if !cn.active || cn.z < 0.5 || cn.z > g.cfg.farZ {
+			continue
+		}
+		coinRow := zRow(cn.z)
+		if y != coinRow && !(cn.gold && y == coinRow-1) {
+			continue
+		}
+		cnTw := int(fullTw * (1.0 - cn.z/g.cfg.farZ))
+		if cnTw < 3 {
+			continue
+		}
+		cnLeft := g.width/2 + g.curveShift(cn.z) - cnTw/2
+		cnLW := float64(cnTw) / float64(numLanes)
+		set(cnLeft+int(g.snap(cn.x)*cnLW+cnLW*0.5), pxCoin)
+	}
+	return left, right
+}
+
+// appendHalfBlock appends a rendered row to b for the terminal, turning each
+// half-block cell into its glyph and colours from pal. Text goes out as it is,
+// in the SGR text.
+func appendHalfBlock(b, line []byte, pal []int, text string) []byte {
+	fg, bg := 0, 0
+	for _, c := range line {
+		if !isHalfCell(c) {
+			if fg != 0 || bg != 0 {
+				b = append(b, text...)
+				fg, bg = 0, 0
+			}
+			b = append(b, c)
+			continue
+		}
+		top, bottom := (c>>3)&7, c&7
+		glyph, wantFg, wantBg := "▀", pal[top], 0
+		switch {
+		case top == bottom:
+			glyph = "█"
+		case top == pxNone:
+			glyph, wantFg = "▄", pal[bottom]
+		case bottom != pxNone:
+			wantBg = pal[bottom] + 10
+		}
+		if wantFg != fg || wantBg != bg {
+			if wantBg == 0 && bg != 0 {
+				b = append(b, text...)
+				fg, bg = 0, 0
+			}
+			b = append(b, "\033["...)
+			b = strconv.AppendInt(b, int64(wantFg), 10)
+			if wantBg != 0 {
+				b = append(b, ';')
+				b = strconv.AppendInt(b, int64(wantBg), 10)
+			}
+			b = append(b, 'm')
+			fg, bg = wantFg, wantBg
+		}
+		b = append(b, glyph...)
+	}
+	if fg != 0 || bg != 0 {
+		b = append(b, text...)
+	}
+	return b
+}
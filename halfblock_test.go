@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestAppendHalfBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		line []byte
+		want string
+	}{
+		{"text", []byte("ab"), "ab"},
+		{"top", []byte{halfCell(pxRail, pxNone)}, "\033[37m▀\033[0m"},
+		{"bottom", []byte{halfCell(pxNone, pxCoin)}, "\033[93m▄\033[0m"},
+		{"both the same", []byte{halfCell(pxTie, pxTie)}, "\033[33m█\033[0m"},
+		{"two colours", []byte{halfCell(pxBarrier, pxCoin)}, "\033[31;103m▀\033[0m"},
+		{"colour carries on", []byte{halfCell(pxRail, pxNone), halfCell(pxRail, pxNone), 'x'}, "\033[37m▀▀\033[0mx"},
+		{"background dropped", []byte{halfCell(pxBarrier, pxCoin), halfCell(pxRail, pxNone)}, "\033[31;103m▀\033[0m\033[37m▀\033[0m"},
+	}
+	for _, tt := range tests {
+		if got := string(appendHalfBlock(nil, tt.line, pxSGR[:], plainText)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHalfblockFrame(t *testing.T) {
+	g := testGame(t, 80, 24, "-halfblock", "-mode", "practice", "-debug-render")
+	for range 100 {
+		g.update(0.05)
+	}
+	rows := screenRows(g)
+	cells := 0
+	for _, row := range rows {
+		for i := range len(row) {
+			if isHalfCell(row[i]) {
+				cells++
+			}
+		}
+	}
+	if cells == 0 {
+		t.Error("no half-block cells in the frame")
+	}
+
+	// The horizon and up are text as ever
+	for i, row := range rows[:3] {
+		for j := range len(row) {
+			if isHalfCell(row[j]) {
+				t.Fatalf("half-block cell up in the sky at row %d: %q", i, row)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+)
+
+// --- Headless runs and results ---
+
+// resultsVersion goes up whenever a results field changes meaning or goes
+// away. New fields can be added without bumping it.
+const resultsVersion = 1
+
+// headlessMaxSecs stops a headless run that would otherwise go on forever,
+// like a practice run or a lucky autopilot.
+const headlessMaxSecs = 600
+
+// results is how a finished run is reported to scripts, one JSON object on
+// stdout.
+type results struct {
+	Version  int     `json:"version"`
+	Seed     int64   `json:"seed"`
+	Score    int     `json:"score"`
+	Coins    int     `json:"coins"`
+	Distance float64 `json:"distance"`
+	Duration float64 `json:"duration"` // seconds of running, not counting the countdown
+	TopSpeed float64 `json:"top_speed"`
+	Scoring  scoring `json:"scoring"`
+}
+
+// scoring is what the points were worth in a run. Scores only compare
+// between runs that scored the same way.
+type scoring struct {
+	Coin     int     `json:"coin"`
+	Distance float64 `json:"distance"`
+}
+
+func (g *game) results() results {
+	return results{
+		Version:  resultsVersion,
+		Seed:     g.seed,
+		Score:    g.score,
+		Coins:    g.coins,
+		Distance: math.Round(g.distance*100) / 100,
+		Duration: math.Round(g.elapsed*100) / 100,
+		TopSpeed: math.Round(g.topSpeed*100) / 100,
+		Scoring:  scoring{Coin: g.cfg.coinValue, Distance: g.cfg.distanceValue},
+	}
+}
+
+func writeResults(out io.Writer, g *game) error {
+	return json.NewEncoder(out).Encode(g.results())
+}
+
+// runHeadless plays a whole run on autopilot as fast as it can, without a
+// terminal or any rendering, and prints its results.
+func runHeadless(cfg config, out io.Writer, logger *slog.Logger) error {
+	w, h := benchWidth, benchHeight
+	if cfg.width > 0 {
+		w, h = cfg.width, cfg.height
+	}
+	g := newGame(w, h, cfg)
+	g.log = logger
+	dt := 1.0 / targetFPS
+	for !g.ended() && g.elapsed < headlessMaxSecs {
+		g.update(dt)
+	}
+	return writeResults(out, g)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- Custom HUD layouts ---
+//
+// -hud-layout swaps the built-in HUD for rows of your own, written as text
+// with fields in braces and | between rows:
+//
+//	S:{score} C:{coins}|{speed}m/s
+//
+// A field that doesn't apply to the run, like {lives} outside forgiving
+// mode, comes out empty, and a row whose fields all come out empty is left
+// out. Unlike the built-in rows there are no shorter versions to fall back
+// on, so a row has to fit on an 80 column screen, or the -size one, and on
+// anything narrower it's cut off at the edge.
+
+const comboBarCols = 8 // how long {combo}'s meter is
+
+// hudField is something a layout can show: how wide it usually gets, for
+// checking a row fits, and what it says right now.
+type hudField struct {
+	width func(c config) int
+	text  func(g *game) string
+}
+
+// scoreCols is how wide a score usually gets in the -score-format style: up
+// to seven digits.
+func scoreCols(c config) int {
+	return len(c.scoreText(9999999, true))
+}
+
+var hudFields = map[string]hudField{
+	"score":    {scoreCols, func(g *game) string { return g.cfg.scoreText(g.score, true) }},
+	"coins":    {fixedCols(5), func(g *game) string { return strconv.Itoa(g.coins) }},
+	"speed":    {fixedCols(5), func(g *game) string { return fmt.Sprintf("%.1f", g.speed) }},
+	"distance": {fixedCols(6), func(g *game) string { return strconv.Itoa(int(g.distance)) }},
+	"time":     {fixedCols(4), func(g *game) string { return strconv.Itoa(int(g.elapsed)) }},
+	"combo": {fixedCols(comboBarCols + 2), func(g *game) string {
+		return "[" + meterBar(g.comboLevel(), comboBarCols) + "]"
+	}},
+	"bonus": {fixedCols(2), func(g *game) string {
+		switch {
+		case g.superT > 0:
+			return fmt.Sprintf("x%d", superMultiplier)
+		case g.inBonusZone():
+			return "x2"
+		}
+		return ""
+	}},
+	"lives": {fixedCols(1), func(g *game) string {
+		if g.cfg.mode != modeForgiving {
+			return ""
+		}
+		return strconv.Itoa(g.lives)
+	}},
+	"best": {scoreCols, func(g *game) string {
+		if !g.cfg.seeded {
+			return ""
+		}
+		return g.cfg.scoreText(g.seedBest, true)
+	}},
+	"pace": {func(c config) int { return scoreCols(c) + 1 }, func(g *game) string {
+		gap, ok := g.paceGap()
+		if !ok {
+			return ""
+		}
+		if gap < 0 {
+			return "-" + g.cfg.scoreText(-gap, false)
+		}
+		return "+" + g.cfg.scoreText(gap, false)
+	}},
+	"try": {fixedCols(3), func(g *game) string {
+		if !g.cfg.challenge {
+			return ""
+		}
+		return strconv.Itoa(g.attempt)
+	}},
+}
+
+func fixedCols(n int) func(config) int {
+	return func(config) int { return n }
+}
+
+// hudPart is a stretch of a layout row: literal text, or a field if field
+// is set.
+type hudPart struct {
+	text  string
+	field string
+}
+
+// hudLayout is a parsed -hud-layout, a row of parts per HUD row.
+type hudLayout [][]hudPart
+
+// parseHUDLayout reads a -hud-layout, turning away fields it doesn't know,
+// braces that don't pair up, and text that isn't printable ASCII, which would
+// throw the HUD's columns off.
+func parseHUDLayout(s string) (hudLayout, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, errors.New("HUD layout is empty, leave it off for the built-in one")
+	}
+	var layout hudLayout
+	for _, line := range strings.Split(s, "|") {
+		var row []hudPart
+		for line != "" {
+			open := strings.IndexAny(line, "{}")
+			if open < 0 {
+				row = append(row, hudPart{text: line})
+				break
+			}
+			if line[open] == '}' {
+				return nil, fmt.Errorf("} with no { before it in %q", line)
+			}
+			if open > 0 {
+				row = append(row, hudPart{text: line[:open]})
+			}
+			end := strings.IndexAny(line[open+1:], "{}")
+			if end < 0 || line[open+1+end] == '{' {
+				return nil, fmt.Errorf("{ with no } after it in %q", line)
+			}
+			name := line[open+1 : open+1+end]
+			if _, ok := hudFields[name]; !ok {
+				return nil, fmt.Errorf("unknown HUD field {%s} (want one of: %s)", name, hudFieldNames())
+			}
+			row = append(row, hudPart{field: name})
+			line = line[open+end+2:]
+		}
+		for _, p := range row {
+			if !printable(p.text) {
+				return nil, fmt.Errorf("HUD layout text must be printable ASCII, got %q", p.text)
+			}
+		}
+		layout = append(layout, row)
+	}
+	return layout, nil
+}
+
+// hudFieldNames lists the fields a layout can use, sorted, for messages.
+func hudFieldNames() string {
+	names := make([]string, 0, len(hudFields))
+	for name := range hudFields {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// widest is how wide the layout's widest row usually gets on screen,
+// padding included. Text is all ASCII, so its length is its columns.
+func (l hudLayout) widest(c config) int {
+	widest := 0
+	for _, row := range l {
+		w := 2
+		for _, p := range row {
+			if p.field != "" {
+				w += hudFields[p.field].width(c)
+			} else {
+				w += len(p.text)
+			}
+		}
+		widest = max(widest, w)
+	}
+	return widest
+}
+
+// layoutRows is hudRows for a -hud-layout.
+func (g *game) layoutRows() [][]string {
+	var rows [][]string
+	var b strings.Builder
+	for _, row := range g.cfg.hudLayout {
+		b.Reset()
+		fields, empty := 0, 0
+		for _, p := range row {
+			if p.field == "" {
+				b.WriteString(p.text)
+				continue
+			}
+			v := hudFields[p.field].text(g)
+			fields++
+			if v == "" {
+				empty++
+			}
+			b.WriteString(v)
+		}
+		if fields > 0 && fields == empty {
+			continue
+		}
+		rows = append(rows, []string{" " + b.String() + " "})
+	}
+	return rows
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHUDLayout(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   hudLayout
+		err    string // part of the error, "" for none
+	}{
+		{"S:{score}", hudLayout{{{text: "S:"}, {field: "score"}}}, ""},
+		{"{score}{coins}", hudLayout{{{field: "score"}, {field: "coins"}}}, ""},
+		{"S:{score} C:{coins}|{speed}m/s", hudLayout{
+			{{text: "S:"}, {field: "score"}, {text: " C:"}, {field: "coins"}},
+			{{field: "speed"}, {text: "m/s"}},
+		}, ""},
+		{"just text", hudLayout{{{text: "just text"}}}, ""},
+		{"a||b", hudLayout{{{text: "a"}}, nil, {{text: "b"}}}, ""},
+		{"", nil, "empty"},
+		{"   ", nil, "empty"},
+		{"{nope}", nil, "unknown HUD field {nope}"},
+		{"{}", nil, "unknown HUD field {}"},
+		{"{score", nil, "{ with no }"},
+		{"{sc{ore}", nil, "{ with no }"},
+		{"score}", nil, "} with no {"},
+		{"é {score}", nil, "printable ASCII"},
+		{"{score} 🚀", nil, "printable ASCII"},
+		{"S:\t{score}", nil, "printable ASCII"},
+		{"S:{score}\x1b[31m", nil, "printable ASCII"},
+	}
+	for _, tt := range tests {
+		got, err := parseHUDLayout(tt.layout)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%q: %v", tt.layout, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%q: error %v, want one about %q", tt.layout, err, tt.err)
+		case tt.err == "" && !reflect.DeepEqual(got, tt.want):
+			t.Errorf("%q: got %+v, want %+v", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestHUDLayoutWidest(t *testing.T) {
+	tests := []struct {
+		layout string
+		args   []string
+		want   int
+	}{
+		{"S:{score}", nil, 2 + 2 + 7},
+		{"S:{score}", []string{"-score-format", "grouped"}, 2 + 2 + 9},
+		{"{coins}|{distance} far", nil, 2 + 6 + 4},
+		{"{combo}", nil, 2 + comboBarCols + 2},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, append([]string{"-hud-layout", tt.layout}, tt.args...)...)
+		if got := cfg.hudLayout.widest(cfg); got != tt.want {
+			t.Errorf("%q %q: widest = %d, want %d", tt.layout, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestHUDLayoutFields(t *testing.T) {
+	tests := []struct {
+		field string
+		args  []string
+		set   func(g *game)
+		want  string // "" for the row being left out
+	}{
+		{"score", nil, func(g *game) { g.score = 1234 }, "0001234"},
+		{"score", []string{"-score-format", "compact"}, func(g *game) { g.score = 12345 }, "12.3k"},
+		{"coins", nil, func(g *game) { g.coins = 42 }, "42"},
+		{"speed", nil, func(g *game) { g.speed = 12.34 }, "12.3"},
+		{"distance", nil, func(g *game) { g.distance = 99.9 }, "99"},
+		{"time", nil, func(g *game) { g.elapsed = 61.5 }, "61"},
+		{"combo", nil, func(g *game) { g.combo = 0 }, "[" + strings.Repeat(" ", comboBarCols) + "]"},
+		{"bonus", nil, func(g *game) { g.superT = 1 }, "x3"},
+		{"bonus", nil, func(g *game) {}, ""},
+		{"lives", []string{"-mode", "forgiving"}, func(g *game) { g.lives = 2 }, "2"},
+		{"lives", []string{"-mode", "hardcore"}, func(g *game) {}, ""},
+		{"best", nil, func(g *game) { g.seedBest = 500 }, "0000500"},
+		{"pace", nil, func(g *game) {}, ""},
+		{"pace", nil, func(g *game) { g.bestPace, g.pace = []int{100, 300}, []int{100, 250} }, "-50"},
+		{"try", []string{"-challenge"}, func(g *game) { g.attempt = 4 }, "4"},
+		{"try", nil, func(g *game) {}, ""},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, append([]string{"-hud-layout", "<{" + tt.field + "}>"}, tt.args...)...)
+		tt.set(g)
+		rows := g.layoutRows()
+		want := [][]string{{" <" + tt.want + "> "}}
+		if tt.want == "" {
+			want = nil
+		}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("{%s} %q: rows %q, want %q", tt.field, tt.args, rows, want)
+		}
+	}
+}
+
+func TestHUDLayoutRenders(t *testing.T) {
+	// Every field at once, drawn with -debug-render, which panics on a row
+	// that isn't exactly the screen's width of ASCII
+	var fields []string
+	for name := range hudFields {
+		fields = append(fields, "{"+name+"}")
+	}
+	layout := strings.Join(fields[:len(fields)/2], " ") + "|" + strings.Join(fields[len(fields)/2:], " ") + "|text only"
+	for _, w := range []int{80, 40, 20} {
+		g := testGame(t, w, 24, "-hud-layout", layout, "-mode", "forgiving", "-challenge", "-debug-render")
+		screenRows(g)
+	}
+}
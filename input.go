@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// --- Keyboard input ---
+//
+// Keys don't arrive a byte at a time. Arrows come as escape sequences, other
+// scripts as multi-byte UTF-8, and a paste as one big lump. keyDecoder turns
+// the bytes back into key presses, holding on to a key that's only partly
+// arrived until the rest turns up.
+
+// keyPress is one key: a character, or one of the special keys below.
+type keyPress rune
+
+// Special keys, numbered past the last rune so they can't clash with one
+const (
+	keyUp keyPress = utf8.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+)
+
+const (
+	keyEsc = 27
+
+	// escWait is how long an ESC waits for the rest of a sequence before it
+	// counts as the escape key on its own
+	escWait = 50 * time.Millisecond
+
+	maxSeqLen = 32 // longest escape sequence worth waiting for, anything longer is junk
+)
+
+type keyDecoder struct {
+	buf []byte // start of a key that hasn't all arrived yet
+}
+
+// feed takes bytes as they come in and returns the keys they finish.
+// Sequences for keys the game has no use for are read and dropped, so they
+// don't turn into stray presses.
+func (d *keyDecoder) feed(p []byte) []keyPress {
+	d.buf = append(d.buf, p...)
+	var keys []keyPress
+	for len(d.buf) > 0 {
+		k, n := decodeKey(d.buf)
+		if n == 0 {
+			if len(d.buf) > maxSeqLen {
+				d.buf = d.buf[:0] // never going to finish
+			}
+			break
+		}
+		if k != 0 {
+			keys = append(keys, k)
+		}
+		d.buf = d.buf[n:]
+	}
+	if len(d.buf) == 0 {
+		d.buf = nil
+	}
+	return keys
+}
+
+// pending reports whether the decoder is partway through a key.
+func (d *keyDecoder) pending() bool {
+	return len(d.buf) > 0
+}
+
+// flush gives up waiting for the rest of a key. A lone ESC was the escape key
+// after all and anything after it gets read afresh, the start of a UTF-8
+// character that never finished is dropped.
+func (d *keyDecoder) flush() []keyPress {
+	if len(d.buf) == 0 || d.buf[0] != keyEsc {
+		d.buf = nil
+		return nil
+	}
+	rest := d.buf[1:]
+	d.buf = nil
+	return append([]keyPress{keyEsc}, d.feed(rest)...)
+}
+
+// decodeKey reads the key at the start of p, returning it and how many bytes
+// it took. It takes 0 bytes if p stops partway through a key, and returns a 0
+// key for a sequence it read but has nothing to report for.
+func decodeKey(p []byte) (keyPress, int) {
+	if p[0] != keyEsc {
+		if p[0] < utf8.RuneSelf {
+			return keyPress(p[0]), 1
+		}
+		if !utf8.FullRune(p) {
+			return 0, 0
+		}
+		r, n := utf8.DecodeRune(p)
+		if r == utf8.RuneError {
+			return 0, n // not UTF-8, skip the byte
+		}
+		return keyPress(r), n
+	}
+
+	if len(p) < 2 {
+		return 0, 0
+	}
+	switch p[1] {
+	case 'O':
+		// SS3, arrows from terminals in application cursor mode
+		if len(p) < 3 {
+			return 0, 0
+		}
+		return arrowKey(p[2]), 3
+	case '[':
+		// CSI: parameter and intermediate bytes, then one final byte
+		for i := 2; i < len(p); i++ {
+			if p[i] < 0x40 || p[i] > 0x7e {
+				continue
+			}
+			// Old-style mouse reports carry three raw bytes after the M
+			if p[i] == 'M' && i == 2 {
+				if len(p) < 6 {
+					return 0, 0
+				}
+				return 0, 6
+			}
+			return arrowKey(p[i]), i + 1
+		}
+		return 0, 0
+	}
+	// ESC then anything else is the escape key, then that key on its own
+	return keyEsc, 1
+}
+
+// arrowKey is the arrow a sequence's final byte stands for, or 0.
+func arrowKey(final byte) keyPress {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	}
+	return 0
+}
+
+// readKeys reads keys from in until it runs dry, handing each to got, and
+// stops early if got returns false.
+func readKeys(in io.Reader, got func(keyPress) bool) {
+	done := make(chan struct{})
+	defer close(done)
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		for {
+			b := make([]byte, 64)
+			n, err := in.Read(b)
+			if err != nil || n == 0 {
+				return
+			}
+			select {
+			case chunks <- b[:n]:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var dec keyDecoder
+	for {
+		var wait <-chan time.Time
+		if dec.pending() {
+			wait = time.After(escWait)
+		}
+		var keys []keyPress
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+			keys = dec.feed(chunk)
+		case <-wait:
+			keys = dec.flush()
+		}
+		for _, k := range keys {
+			if !got(k) {
+				return
+			}
+		}
+	}
+}
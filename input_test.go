@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeyDecoder(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string // as they come off the terminal
+		flush  bool     // the ESC wait runs out after the last chunk
+		keys   []keyPress
+	}{
+		{"plain keys", []string{"adq"}, false, []keyPress{'a', 'd', 'q'}},
+		{"arrows", []string{"\x1b[A\x1b[B\x1b[C\x1b[D"}, false, []keyPress{keyUp, keyDown, keyRight, keyLeft}},
+		{"application mode arrows", []string{"\x1bOA\x1bOD"}, false, []keyPress{keyUp, keyLeft}},
+		{"arrow split across reads", []string{"\x1b", "[", "C"}, false, []keyPress{keyRight}},
+		{"arrow with modifiers", []string{"\x1b[1;5C"}, false, []keyPress{keyRight}},
+		{"unused sequence dropped", []string{"\x1b[15~a"}, false, []keyPress{'a'}},
+		{"old-style mouse report dropped", []string{"\x1b[M !!d"}, false, []keyPress{'d'}},
+		{"mouse report split", []string{"\x1b[M ", "!!", "d"}, false, []keyPress{'d'}},
+		{"utf-8", []string{"é日"}, false, []keyPress{'é', '日'}},
+		{"utf-8 split across reads", []string{"\xe6", "\x97", "\xa5x"}, false, []keyPress{'日', 'x'}},
+		{"bad utf-8 skipped", []string{"\xffa"}, false, []keyPress{'a'}},
+		{"lone esc waits", []string{"\x1b"}, false, nil},
+		{"lone esc after the wait", []string{"\x1b"}, true, []keyPress{keyEsc}},
+		{"esc then a key", []string{"\x1bq"}, false, []keyPress{keyEsc, 'q'}},
+		{"esc then an arrow", []string{"\x1b\x1b[A"}, false, []keyPress{keyEsc, keyUp}},
+		{"unfinished utf-8 dropped after the wait", []string{"a\xe6\x97"}, true, []keyPress{'a'}},
+		{"ctrl-c", []string{"\x03"}, false, []keyPress{3}},
+		{"paste", []string{"wasd \x1b[A"}, false, []keyPress{'w', 'a', 's', 'd', ' ', keyUp}},
+	}
+	for _, tt := range tests {
+		var d keyDecoder
+		var keys []keyPress
+		for _, c := range tt.chunks {
+			keys = append(keys, d.feed([]byte(c))...)
+		}
+		if tt.flush {
+			keys = append(keys, d.flush()...)
+		}
+		if !slices.Equal(keys, tt.keys) {
+			t.Errorf("%s: got %v, want %v", tt.name, keys, tt.keys)
+		}
+		if !tt.flush && len(tt.keys) > 0 && d.pending() {
+			t.Errorf("%s: still waiting on %q", tt.name, d.buf)
+		}
+	}
+}
+
+func TestKeyDecoderGivesUpOnJunk(t *testing.T) {
+	// A CSI sequence that never ends gets dropped once it's longer than any
+	// real one, rather than swallowing keys forever
+	var d keyDecoder
+	junk := append([]byte("\x1b["), make([]byte, maxSeqLen)...)
+	for i := 2; i < len(junk); i++ {
+		junk[i] = '0'
+	}
+	if keys := d.feed(junk); len(keys) > 0 || d.pending() {
+		t.Fatalf("got %v, pending %v", keys, d.pending())
+	}
+	if keys := d.feed([]byte("a")); !slices.Equal(keys, []keyPress{'a'}) {
+		t.Errorf("after junk got %v, want a", keys)
+	}
+}
@@ -0,0 +1,192 @@
+//go:build integration && linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/term"
+)
+
+// The tests in here build the game and play it over a pseudo-terminal, the
+// way someone would: keys go in as bytes, frames and teardown come out as
+// escapes. They need Linux's /dev/ptmx, so they're behind the integration
+// tag:
+//
+//	go test -tags integration .
+
+// openPty opens a pseudo-terminal w by h, returning its master and slave.
+func openPty(t *testing.T, w, h int) (master, slave *os.File) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("no pseudo-terminals here: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		t.Fatalf("couldn't number the pty: %v", err)
+	}
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		t.Fatalf("couldn't unlock the pty: %v", err)
+	}
+	slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatalf("couldn't open the pty's slave: %v", err)
+	}
+	t.Cleanup(func() { slave.Close() })
+
+	size := struct{ rows, cols, x, y uint16 }{uint16(h), uint16(w), 0, 0}
+	if err := ioctl(slave, syscall.TIOCSWINSZ, unsafe.Pointer(&size)); err != nil {
+		t.Fatalf("couldn't size the pty: %v", err)
+	}
+	return master, slave
+}
+
+func ioctl(f *os.File, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// buildGame builds the game into a temporary directory.
+func buildGame(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "subway-surfer")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("couldn't build the game: %v\n%s", err, out)
+	}
+	return bin
+}
+
+// ptyOutput collects everything the game writes to the pty.
+type ptyOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *ptyOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// waitFor waits for s to turn up in the output.
+func (o *ptyOutput) waitFor(t *testing.T, s string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(o.String(), s) {
+			return
+		}
+	}
+	t.Fatalf("never saw %q, got %q", s, o.String())
+}
+
+func TestPtyPlayAndQuit(t *testing.T) {
+	bin := buildGame(t)
+	master, slave := openPty(t, 80, 24)
+	before, err := term.GetState(int(slave.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stats and the save go in a config directory of the test's own
+	home := t.TempDir()
+	cmd := exec.Command(bin, "-skip-intro", "-manual", "-mode", "practice", "-seed", "1")
+	cmd.Env = append(os.Environ(), "XDG_CONFIG_HOME="+home, "TERM=xterm")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out ptyOutput
+	go func() {
+		b := make([]byte, 4096)
+		for {
+			n, err := master.Read(b)
+			out.mu.Lock()
+			out.buf.Write(b[:n])
+			out.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Into the alt screen, a frame or two, then a lane to the right, a jump,
+	// and quit while still in the air
+	out.waitFor(t, "\033[?1049h")
+	out.waitFor(t, "SCORE")
+	for _, keys := range []string{"\033[C", "\033[A", "q"} {
+		time.Sleep(100 * time.Millisecond)
+		if _, err := master.WriteString(keys); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Fatalf("game didn't exit cleanly: %v\n%q", err, out.String())
+		}
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		t.Fatalf("game didn't quit, got %q", out.String())
+	}
+
+	// The teardown comes after the last frame, and puts everything back
+	teardown := "\033[?7h\033[?25h\033[?1049l"
+	out.waitFor(t, teardown)
+	got := out.String()
+	if end, last := strings.LastIndex(got, teardown), strings.LastIndex(got, ";1H"); end < 0 || end < last {
+		t.Errorf("no teardown after the last frame, output ends %q", got[max(len(got)-200, 0):])
+	}
+	after, err := term.GetState(int(slave.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Error("the terminal was left in raw mode")
+	}
+
+	// The frames decode, and the keys got to the game: the save it left on
+	// the way out has the runner a lane over and in the air
+	out.waitFor(t, "-resume carries on from here")
+	fb := newFramebuffer(80, 24)
+	fb.Write([]byte(got[:strings.LastIndex(got, teardown)]))
+	if fb.err != nil {
+		t.Errorf("frames don't decode: %v", fb.err)
+	}
+	data, err := os.ReadFile(filepath.Join(home, "subway-surfer", "save.json"))
+	if err != nil {
+		t.Fatalf("no save on quit: %v", err)
+	}
+	var sv savedGame
+	if err := json.Unmarshal(data, &sv); err != nil {
+		t.Fatal(err)
+	}
+	if sv.TargetLane != 2 {
+		t.Errorf("right arrow left the runner heading for lane %d, want 2", sv.TargetLane)
+	}
+	if sv.JumpT <= 0 {
+		t.Error("up arrow didn't jump")
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// --- Learning mode ---
+//
+// -learn is for getting the hang of it. Obstacles coming up count down the
+// ticks until they reach the runner, and the lane that's clear the furthest
+// ahead gets bracketed, > <, where the runner is. Since it gives the game
+// away, a learning run is never ranked: no seed best, no top speed.
+
+const learnTicks = 3 * targetFPS // how close an obstacle has to be to start counting down
+
+// updateTimings works out how many ticks each obstacle is from the runner
+// and which lane's safest, for drawing. Nothing in the sim depends on them.
+func (g *game) updateTimings() {
+	step := g.speed / targetFPS
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		obs.ticks = 0
+		if obs.active && obs.z >= runnerZ && step > 0 {
+			obs.ticks = int(math.Ceil((obs.z - runnerZ) / step))
+		}
+	}
+	g.safeLane = g.safestLane()
+}
+
+// safestLane is the lane with the longest clear run ahead of it, up to the
+// dodge lookahead, so any lane clear that far counts as clear. Ties go to the
+// lane nearest where the runner's headed, so it only says move when moving
+// helps.
+func (g *game) safestLane() int {
+	best, bestClear := g.targetLane, -1.0
+	for _, l := range g.lanesByDistance() {
+		if clear := g.clearAhead(l); clear > bestClear {
+			best, bestClear = l, clear
+		}
+	}
+	return best
+}
+
+// lanesByDistance is every lane, nearest the runner's target lane first.
+func (g *game) lanesByDistance() []int {
+	lanes := []int{g.targetLane}
+	for d := 1; d < numLanes; d++ {
+		for _, l := range [...]int{g.targetLane - d, g.targetLane + d} {
+			if l >= 0 && l < numLanes {
+				lanes = append(lanes, l)
+			}
+		}
+	}
+	return lanes
+}
+
+// clearAhead is how far down lane the runner could go before a barrier or
+// fire, at most the dodge lookahead.
+func (g *game) clearAhead(lane int) float64 {
+	clear := g.cfg.lookahead
+	if c := g.closure; c.active && c.lane == lane && c.end > runnerZ {
+		clear = math.Min(clear, math.Max(c.start-runnerZ, 0))
+	}
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		if obs.active && obs.kind == kindBarrier && obs.lane == lane && obs.z >= runnerZ {
+			clear = math.Min(clear, obs.z-runnerZ)
+		}
+	}
+	return clear
+}
+
+// drawTimings draws the learning overlay on a row of either view: each
+// coming obstacle's ticks just over it, and the brackets round the safe lane
+// on the runner's rows.
+func (g *game) drawTimings(buf []byte, row, horizon int) {
+	if !g.cfg.learn {
+		return
+	}
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		if !obs.active || obs.z < runnerZ || obs.ticks > learnTicks {
+			continue
+		}
+		if r, x, ok := g.timingSpot(obs, horizon); ok && r == row {
+			label := strconv.Itoa(obs.ticks)
+			placeString(buf, x-len(label)/2, label)
+		}
+	}
+
+	// Brackets just inside the safe lane's edges
+	var left, right, top, bottom int
+	if g.cfg.view == viewTopDown {
+		lw := (g.trackCols() - 2) / numLanes
+		left = g.topDownLaneX(float64(g.safeLane)) + 1
+		right = left + lw - 3
+		bottom = g.topDownRunnerRow()
+		top = bottom - 1
+	} else {
+		rTw := int(float64(g.trackCols()) * runnerDepth)
+		rLeft := g.width/2 + g.curveShift((1-runnerDepth)*g.cfg.farZ) - rTw/2
+		rLW := float64(rTw) / float64(numLanes)
+		left = rLeft + int(float64(g.safeLane)*rLW) + 1
+		right = rLeft + int(float64(g.safeLane+1)*rLW) - 1
+		bottom = horizon + int(runnerDepth*float64(g.height-horizon))
+		top = bottom - 2
+	}
+	if row >= top && row <= bottom {
+		placeString(buf, left, ">")
+		placeString(buf, right, "<")
+	}
+}
+
+// timingSpot is the row and column an obstacle's timing is centred on, just
+// over its top. It's false while the obstacle's too far off to be drawn.
+func (g *game) timingSpot(obs *obstacle, horizon int) (row, x int, ok bool) {
+	if g.cfg.view == viewTopDown {
+		lw := (g.trackCols() - 2) / numLanes
+		top := g.topDownRow(obs.z)
+		if obs.kind == kindBarrier {
+			top--
+		}
+		return top - 1, g.topDownLaneX(float64(obs.lane)) + lw/2, true
+	}
+
+	cols := g.obstacleCols(obs.z)
+	if cols < minObstacleCols {
+		return 0, 0, false
+	}
+	lw := float64(cols) / float64(numLanes)
+	x = g.width/2 + g.curveShift(obs.z) - cols/2 + int((float64(obs.lane)+0.5)*lw)
+	pop := g.obstaclePop(obs)
+	if g.cfg.halfBlock {
+		span := 2 * (g.height - horizon)
+		top := 2*horizon + int((1-obs.z/g.cfg.farZ)*float64(span)) - int(math.Round(4*pop))
+		return top/2 - 1, x, true
+	}
+	return g.zRow(obs.z, horizon) - int(math.Round(2*pop)) - 1, x, true
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestSafestLane(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  int
+		barrier []int // lane, z
+		low     []int // lane, z
+		fire    int   // burning lane, -1 for none
+		want    int
+	}{
+		{"all clear stays put", 1, nil, nil, -1, 1},
+		{"barrier ahead", 1, []int{1, 6}, nil, -1, 0},
+		{"the other way", 0, []int{0, 6}, nil, -1, 1},
+		{"jumpable isn't in the way", 1, nil, []int{1, 6}, -1, 1},
+		{"barrier too far off to matter", 1, []int{1, 20}, nil, -1, 1},
+		{"fire", 0, nil, nil, 0, 1},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-learn")
+		clearTrack(g)
+		g.targetLane = tt.target
+		if tt.barrier != nil {
+			g.obstacles[0] = obstacle{lane: tt.barrier[0], kind: kindBarrier, z: float64(tt.barrier[1]), active: true}
+		}
+		if tt.low != nil {
+			g.obstacles[1] = obstacle{lane: tt.low[0], kind: kindLow, z: float64(tt.low[1]), active: true}
+		}
+		if tt.fire >= 0 {
+			g.closure = closure{lane: tt.fire, start: runnerZ + 5, end: runnerZ + 5 + closureLength, active: true}
+		}
+		if got := g.safestLane(); got != tt.want {
+			t.Errorf("%s: safest lane %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestObstacleTicks(t *testing.T) {
+	// An obstacle counted n ticks off reaches the runner on the nth tick
+	g := testGame(t, 80, 24, "-learn", "-mode", "practice", "-speed-ramp", "0")
+	clearTrack(g)
+	g.obstacles[0] = obstacle{lane: 2, kind: kindBarrier, z: 12, active: true}
+	g.updateTimings()
+	n := g.obstacles[0].ticks
+	if n <= 0 {
+		t.Fatalf("obstacle 12 off counted %d ticks", n)
+	}
+	for i := 1; i <= n; i++ {
+		if g.obstacles[0].z < runnerZ {
+			t.Fatalf("reached the runner on tick %d, counted %d", i-1, n)
+		}
+		g.update(1.0 / targetFPS)
+	}
+	if g.obstacles[0].z >= runnerZ {
+		t.Errorf("still %v off after the %d ticks counted", g.obstacles[0].z-runnerZ, n)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// openLog starts a structured event log at path. Records are buffered so
+// logging stays cheap mid-frame; call the returned close func to flush them.
+// It's safe to call while the game's still logging, from another goroutine,
+// which is how a forced exit gets the log out.
+func openLog(path string) (*slog.Logger, func() error, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := &logWriter{bw: bufio.NewWriter(f)}
+
+	closeLog := func() error {
+		if err := w.flush(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	return slog.New(slog.NewTextHandler(w, nil)), closeLog, nil
+}
+
+// logWriter is a buffered writer that can be flushed while it's written to.
+type logWriter struct {
+	mu sync.Mutex
+	bw *bufio.Writer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.bw.Write(p)
+}
+
+func (w *logWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.bw.Flush()
+}
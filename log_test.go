@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCloseLogWhileLogging(t *testing.T) {
+	// A forced exit flushes the log from the signal goroutine while the game
+	// may still be logging on its own
+	path := filepath.Join(t.TempDir(), "run.log")
+	logger, closeLog, err := openLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("first")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 1000 {
+			logger.Info("tick")
+		}
+	}()
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "msg=first") {
+		t.Errorf("log lost what was logged before it closed: %q", data)
+	}
+	for _, l := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if !strings.HasPrefix(l, "time=") {
+			t.Errorf("torn line in the log: %q", l)
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"math"
 	"math/rand"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -13,71 +21,278 @@ import (
 )
 
 const (
-	targetFPS    = 20
-	numLanes     = 3
-	laneWidth    = 7
-	trackWidth   = numLanes*laneWidth + 4 // 3 lanes + borders
-	farZ           = 20
-	spawnZ         = farZ - 1
-	dodgeLookahead = 8
+	targetFPS  = 20
+	numLanes   = 3
+	laneWidth  = 7
+	trackWidth = numLanes*laneWidth + 4 // 3 lanes + borders
+
+	defaultFarZ    = 20 // default -view-distance
+	spawnInset     = 1  // how far short of the view distance things spawn, unless -spawn-distance says
+	dodgeLookahead = 8  // default -lookahead
+
+	obstaclePoolSize = 20
+	coinPoolSize     = 30
+	coinsPerLine     = 3
+	diagonalChance   = 0.3 // share of coin lines laid out as a diagonal
+	goldChance       = 0.1 // share of coin lines that are gold
+	goldMultiplier   = 5   // what a gold coin is worth in plain ones
+	grabsPerSpacing  = 2   // most coins the runner can grab per -coin-spacing of track run
+	obstacleReserve  = 2   // room kept for obstacles when coins are near the object cap
+	minObjects       = coinsPerLine + obstacleReserve
+	runnerZ          = 1.0        // where the runner meets things on the track
+	runnerDepth      = 0.85       // how far down the ground the runner stands in the perspective view
+	minPlayWidth     = trackWidth // default -min-size
+	minPlayHeight    = 8
+	pullZ            = 4.0 // how far out coins in reach start sliding toward the runner
+	minObstacleCols  = 3   // narrowest track an obstacle gets drawn on
+
+	practiceHitCost = 500 // points lost per hit in practice mode
+	countdownSecs   = 3.0 // 3-2-1 before the run starts
+	goSecs          = 0.5 // how long GO! stays up once it does
+	curveCols       = 12  // how far the track bends at the horizon, at most
+	daySecs         = 90  // half a day/night cycle
+	shakeSecs       = 0.4 // camera shake after a crash
+	shakeCols       = 3   // how far the camera shakes at first
+	popSecs         = 0.2 // how long a new obstacle takes to grow to full size
+	fairStartSecs   = 1.0 // nothing spawns in the runner's lane this early in a run
+	hintSecs        = 5.0 // how long the controls bar stays up into a run
+	speedoMinWidth  = 40  // narrowest terminal the speedometer shows on
+	speedoSecs      = 120 // time an uncapped run takes to peg the speedometer
+	retrySecs       = 1.0 // how long a lost -challenge run shows before it starts over
+
+	coinEvery       = 0.6  // seconds between coin lines
+	rushCoinEvery   = 0.2  // seconds between coin lines in a coin rush
+	bonusZoneEvery  = 30.0 // seconds between coin doubler zones
+	bonusZoneLength = 40.0 // track length of a zone
+
+	coinFarDepth  = 0.35 // coins further up the track than this are just a speck
+	coinNearDepth = 0.7  // coins nearer than this get bracketed, (o)
+	coinNearCols  = 5    // narrowest lane a bracketed coin fits in
 )
 
 // --- Game state ---
 
 type obstacle struct {
 	lane   int
+	kind   int
 	z      float64
+	shown  float64 // seconds since it came into view
+	ticks  int     // ticks until it reaches the runner, worked out for -learn
 	active bool
 }
 
 type coinObj struct {
 	lane   int
+	x      float64 // lane it's drawn in, pulled toward the runner when in reach
 	z      float64
+	gold   bool // worth goldMultiplier coins
 	active bool
 }
 
+// bonusZone is a stretch of track where coins are worth double. Its start and
+// end travel toward the viewer like any other object.
+type bonusZone struct {
+	start, end float64
+	active     bool
+}
+
 type game struct {
+	cfg           config
+	rng           *rand.Rand      // the only source of randomness in the sim
+	src           *countingSource // rng's source when the game seeded it, for saving
+	log           *slog.Logger
+	seed          int64
+	seedBest      int   // best score on this seed, seeded runs only
+	attempt       int   // which try at the course this is, counting up in -challenge
+	bestScore     int   // best score of the earlier tries in -challenge
+	pace          []int // score every paceEvery of track this run
+	bestPace      []int // the seed best's pace, nil with none to race
+	triedPace     []int // pace of the best of the earlier tries in -challenge
 	width, height int
+	clipW, clipH  int // visible part of a forced -size bigger than the terminal, 0 for all of it
+	top           int // screen row the game starts on, below the kept scrollback with -no-altscreen
+	safeLane      int // lane with the longest clear run ahead, for -learn
 	speed         float64
+	topSpeed      float64   // fastest the run has gone
+	bestSpeed     float64   // fastest any run had gone before this one, from the stats
+	speeds        []float64 // speed every speedEvery of running, for the share card
 	score         int
 	coins         int
 	runnerLane    int
 	targetLane    int
+	steeredAt     float64 // elapsed time of the last lane change
 	laneX         float64 // smooth interpolation
-	obstacles     [20]obstacle
-	coinPool      [30]coinObj
+	laneFrom      float64 // where laneX was when the lane change started
+	laneT         float64 // how far through the lane change, 0 to 1
+	jumpT         float64 // time left in the air
+	slideT        float64 // time left sliding
+	stride        float64 // where the legs are in the walk cycle, 0 to 4
+	obstacles     [obstaclePoolSize]obstacle
+	coinPool      [coinPoolSize]coinObj
 	scrollOff     float64
+	distance      float64 // track covered so far
+	curve         float64 // how hard the track bends, -1 (left) to 1 (right)
+	finished      bool    // crossed the finish line in level mode
+	over          bool    // crashed out of the run
+	lives         int
+	paused        bool
+	hintsOn       bool    // controls bar showing
+	hintsPinned   bool    // controls bar brought back with ?, so it stays
+	countdown     float64 // seconds left before the run starts
+	shake         float64 // seconds of camera shake left
 	elapsed       float64
 	spawnTimer    float64
+	patternTimer  float64 // time since the last obstacle pattern
+	courseNext    int     // next spawn in the -course script
 	coinTimer     float64
+	wavePhase     float64 // where in the density wave the run starts
+	zone          bonusZone
+	zoneTimer     float64
+	closure       closure // stretch of an outside lane on fire, see closures.go
+	closureTimer  float64 // time since the last lane closure
+	gap           gap     // stretch of track with no ground, see gaps.go
+	gapTimer      float64 // time since the last gap
+	rushTimer     float64 // time since the last coin rush
+	rushT         float64 // time left in the coin rush, obstacles hold off until it's done
+	combo         int     // coin combo meter, 0 to comboFull
+	superT        float64 // time left on the super multiplier
+	popups        [popupPoolSize]popup
 	frame         []byte
+	screen        []byte     // what's on screen, row after row, to diff the next frame against
+	redraw        bool       // send every row next frame, not just the ones that changed
+	rowBuf        []byte     // reused by renderRow
+	pxTop         []byte     // reused by drawGroundHalf
+	pxBottom      []byte     // reused by drawGroundHalf
+	hud           [][]string // this frame's HUD rows
+	hudBar        string     // this frame's HUD squeezed onto one line, for -hud bottom
+	summary       []string   // this frame's end of run panel, if any
+	tuneSel       int        // parameter the tuning panel's adjusting
+	tunePanel     []string   // this frame's tuning panel, tune builds only
+
+	debugFB *framebuffer // the screen as -debug-render decodes it from frames
 }
 
-func newGame(w, h int) *game {
+func newGame(w, h int, cfg config) *game {
+	seed := cfg.seed
+	if !cfg.seeded {
+		seed = time.Now().UnixNano()
+	}
+
+	src := seedSource(seed)
+	g := newGameWithSource(w, h, cfg, src)
+	g.seed, g.src = seed, src
+	return g
+}
+
+// newGameWithSource is newGame with every random draw in the simulation
+// coming from src, for embedders that bring their own RNG (to keep networked
+// clients in sync, say). The game has no seed of its own in that case.
+func newGameWithSource(w, h int, cfg config, src rand.Source) *game {
 	g := &game{
+		cfg:        cfg,
+		rng:        rand.New(src),
+		log:        slog.New(slog.DiscardHandler),
 		width:      w,
 		height:     h,
-		speed:      6.0,
+		speed:      cfg.baseSpeed,
+		score:      cfg.startScore,
+		lives:      cfg.lives,
 		runnerLane: 1,
 		targetLane: 1,
 		laneX:      1.0,
+		hintsOn:    cfg.hints && !cfg.demo,
+		attempt:    1,
+	}
+	g.wavePhase = g.rng.Float64() * 2 * math.Pi
+
+	// Put something on the track to look at during the countdown, unless
+	// there's a course to say what goes on it
+	g.countdown = countdownSecs
+	if cfg.script == nil {
+		g.spawnObstacle()
+		g.spawnCoin()
 	}
 	return g
 }
 
+// restart starts the course over from the top for -challenge, on the same
+// seed so it's the same course, with no countdown, keeping the screen as it
+// is and the count of tries and the best of them. The top speed carries over
+// too, so it's the session's.
+func (g *game) restart() {
+	src := seedSource(g.seed)
+	ng := newGameWithSource(g.width, g.height, g.cfg, src)
+	ng.seed, ng.src, ng.seedBest, ng.bestPace = g.seed, src, g.seedBest, g.bestPace
+	ng.log, ng.frame = g.log, g.frame
+	ng.clipW, ng.clipH, ng.top = g.clipW, g.clipH, g.top
+	ng.attempt = g.attempt + 1
+	ng.bestScore, ng.triedPace = g.bestRun()
+	ng.topSpeed, ng.bestSpeed = g.topSpeed, g.bestSpeed
+	ng.hintsOn, ng.countdown = false, 0
+	g.log.Info("restart", "t", g.elapsed, "attempt", ng.attempt, "score", g.score, "best", ng.bestScore)
+	*g = *ng
+}
+
 func (g *game) update(dt float64) {
+	// A step that doesn't move time forward does nothing, rather than running
+	// the sim backwards or filling every timer with NaN
+	if !(dt > 0) || math.IsInf(dt, 0) {
+		return
+	}
+
+	// Camera shake settles even once the run is over
+	if !g.paused && !g.tooSmall() {
+		g.shake = math.Max(g.shake-dt, 0)
+		g.updatePopups(dt)
+	}
+
+	// Every timer below only moves with dt, so bailing out here freezes
+	// them all together and they pick up again without a jump
+	if g.frozen() {
+		return
+	}
+	if g.countdown > 0 {
+		g.countdown -= dt
+		return
+	}
+
 	g.elapsed += dt
-	g.score += int(g.speed * dt * 10)
+	g.score += int(g.speed * dt * g.cfg.distanceValue)
+	if g.elapsed >= hintSecs && !g.hintsPinned {
+		g.hintsOn = false
+	}
 
-	// Speed up over time
-	g.speed = 6.0 + g.elapsed*0.05
-	if g.speed > 16.0 {
-		g.speed = 16.0
+	// Speed up as the run goes on, a zero cap means uncapped
+	g.speed = g.rampSpeed()
+	if g.cfg.maxSpeed > 0 && g.speed > g.cfg.maxSpeed {
+		g.speed = g.cfg.maxSpeed
 	}
+	g.topSpeed = math.Max(g.topSpeed, g.speed)
 
-	g.scrollOff += g.speed * dt
+	// Legs keep pace with the speed, but never skip a pose however long the
+	// frame, so a slow terminal doesn't make them twitch
+	g.stride = math.Mod(g.stride+math.Min(dt*g.cfg.strideRate*g.speed/g.cfg.baseSpeed, 1), 4)
 
-	// Move obstacles toward viewer
+	// Wrap the scroll so the texture math keeps its precision at any speed
+	// (12 is a whole period of both the divider and cross-tie patterns)
+	g.scrollOff = math.Mod(g.scrollOff+g.speed*dt, 12)
+
+	// Level mode ends when the finish line reaches the runner
+	g.distance += g.speed * dt
+	g.trackPace()
+	g.trackSpeed()
+	if !g.cfg.straight {
+		g.curve = 0.7*math.Sin(g.distance/150) + 0.3*math.Sin(g.distance/47)
+	}
+	if g.cfg.levelLength > 0 && g.distance >= g.cfg.levelLength-runnerZ {
+		g.finished = true
+		g.log.Info("finish", "t", g.elapsed, "score", g.score)
+		return
+	}
+
+	// Move obstacles toward viewer, crashing into any that reach the runner
+	// in the lane we're mostly in
+	runnerAt := int(math.Round(g.laneX))
 	for i := range g.obstacles {
 		if !g.obstacles[i].active {
 			continue
@@ -86,86 +301,254 @@ func (g *game) update(dt float64) {
 		if g.obstacles[i].z < -1 {
 			g.obstacles[i].active = false
 		}
+		if g.obstacleCols(g.obstacles[i].z) >= minObstacleCols {
+			g.obstacles[i].shown += dt
+		}
+		// A hit just after steering away gets let off within the leniency
+		// window, so a dodge that was in motion still counts. Jumps and
+		// slides only run down after this, so one still going at the start
+		// of the tick clears whatever goes by during it, even if it ends
+		// partway through
+		if g.obstacles[i].lane == runnerAt && g.obstacles[i].z < runnerZ && g.obstacles[i].z+g.speed*dt >= runnerZ && !g.clears(g.obstacles[i].kind) {
+			if g.dodgedLate(g.obstacles[i].lane) {
+				g.log.Info("dodge.late", "t", g.elapsed, "lane", g.obstacles[i].lane, "since", g.elapsed-g.steeredAt)
+				continue
+			}
+			g.obstacles[i].active = false
+			g.crash()
+			if g.over {
+				break
+			}
+		}
+	}
+	g.updateClosure(dt, runnerAt)
+	g.updateGap(dt)
+	if g.over {
+		return
 	}
 
-	// Move coins
+	// Move coins, picking out the ones in reach. That also catches coins
+	// that skipped the window in one step
+	var inReach [coinPoolSize]int
+	reach := inReach[:0]
 	for i := range g.coinPool {
 		if !g.coinPool[i].active {
 			continue
 		}
 		g.coinPool[i].z -= g.speed * dt
+		g.pullCoin(&g.coinPool[i])
+		if g.inCoinReach(g.coinPool[i], dt) {
+			reach = append(reach, i)
+			continue
+		}
 		if g.coinPool[i].z < -1 {
 			g.coinPool[i].active = false
+			g.comboMiss()
 		}
-		// Collect
-		if g.coinPool[i].z < 2.0 && g.coinPool[i].z > 0 && g.coinPool[i].lane == g.runnerLane {
-			g.coinPool[i].active = false
-			g.coins++
-			g.score += 50
+	}
+
+	// Grab the most valuable first, since there's only so many hands. The
+	// rest get another go next tick if they're still in reach. The hands
+	// keep up with the track rather than the frame rate, so a fast tick that
+	// runs past a whole line doesn't drop the end of it
+	slices.SortStableFunc(reach, func(a, b int) int {
+		return g.coinValue(g.coinPool[b]) - g.coinValue(g.coinPool[a])
+	})
+	grabs := max(grabsPerSpacing, int(math.Ceil(grabsPerSpacing*g.speed*dt/g.cfg.coinSpacing)))
+	for n, i := range reach {
+		c := &g.coinPool[i]
+		if n >= grabs {
+			if c.z < -1 {
+				c.active = false
+				g.comboMiss()
+			}
+			continue
 		}
+		c.active = false
+		g.coins++
+		value := g.coinValue(*c)
+		g.score += value
+		g.addPopup(value)
+		g.comboCoin()
+		g.log.Info("coin", "t", g.elapsed, "lane", c.lane, "gold", c.gold, "value", value)
 	}
 
-	// Spawn obstacles
-	g.spawnTimer += dt
-	interval := 2.0 - g.speed*0.06
-	if interval < 0.7 {
-		interval = 0.7
+	// Move the bonus zone, and send a new one down the track now and then
+	if g.zone.active {
+		g.zone.start -= g.speed * dt
+		g.zone.end -= g.speed * dt
+		if g.zone.end < -1 {
+			g.zone.active = false
+		}
 	}
-	if g.spawnTimer >= interval {
-		g.spawnTimer -= interval
-		g.spawnObstacle()
+	g.zoneTimer += dt
+	if g.zoneTimer >= bonusZoneEvery {
+		g.zoneTimer -= bonusZoneEvery
+		g.zone = bonusZone{
+			start:  g.cfg.spawnZ,
+			end:    g.cfg.spawnZ + bonusZoneLength,
+			active: true,
+		}
 	}
 
-	// Spawn coins
-	g.coinTimer += dt
-	if g.coinTimer >= 0.6 {
-		g.coinTimer -= 0.6
-		g.spawnCoin()
+	// Coin rushes come around on a timer, so seeded runs get them at the
+	// same moments
+	g.rushT = math.Max(g.rushT-dt, 0)
+	if g.cfg.rushEvery > 0 {
+		g.rushTimer += dt
+		if g.rushTimer >= g.cfg.rushEvery {
+			g.rushTimer -= g.cfg.rushEvery
+			g.rushT = g.cfg.rushSecs
+			g.log.Info("rush", "t", g.elapsed, "secs", g.cfg.rushSecs)
+		}
+	}
+
+	g.spawn(dt)
+
+	// Jumps, slides and the super multiplier run out, after the collisions
+	// above have had the tick's worth of them
+	g.jumpT = math.Max(g.jumpT-dt, 0)
+	g.slideT = math.Max(g.slideT-dt, 0)
+	g.superT = math.Max(g.superT-dt, 0)
+
+	if g.cfg.learn {
+		g.updateTimings()
 	}
 
 	// Auto-dodge
-	g.autoDodge()
+	if !g.cfg.manual {
+		g.autoDodge()
+	}
 
-	// Smooth lane transition
-	target := float64(g.targetLane)
-	diff := target - g.laneX
-	if diff > 0.05 {
-		g.laneX += dt * 8
-		if g.laneX > target {
-			g.laneX = target
+	// Smooth lane transition, see easing.go
+	g.moveLane(dt)
+}
+
+// spawn runs the spawners for a tick. The order of random draws is what makes
+// a seed replay the same course, so it's fixed:
+//
+//  1. the wave phase, once when the game is made
+//  2. an obstacle, if its timer is up: kind, lane, and another lane during
+//     the fair start if the first one was the runner's. Or when a pattern's
+//     due and there's room for one, just which pattern
+//  3. then a coin line, if its timer is up: lane, whether it's a diagonal,
+//     which way it goes if so, and whether it's gold
+//  4. then a lane closure, if its timer is up and neither outside lane
+//     burning would box the runner in: which lane, only if both could
+//
+// Gaps draw nothing, so they don't need a place in it. newGame does 2 then 3
+// once to put something on the track for the countdown. A spawner held back
+// by the object cap draws nothing, and obstacles don't spawn (or draw) at all
+// during a coin rush or while a gap's coming in. Anything
+// new that draws from rng has to slot into this list, not just go wherever.
+// A -course script replaces all of it and draws nothing.
+func (g *game) spawn(dt float64) {
+	if g.cfg.script != nil {
+		g.spawnScripted()
+		return
+	}
+	if g.rushT == 0 && !g.gapComingIn() {
+		g.patternTimer += dt
+		g.spawnTimer += dt
+		interval := 2.0 - g.speed*0.06
+		if interval < 0.7 {
+			interval = 0.7
 		}
-	} else if diff < -0.05 {
-		g.laneX -= dt * 8
-		if g.laneX < target {
-			g.laneX = target
+		interval *= g.waveFactor() * g.cfg.spawnScale
+		if g.spawnTimer >= interval {
+			g.spawnTimer -= interval
+			if g.patternTimer >= patternEvery && g.patternFits() {
+				g.patternTimer = 0
+				g.spawnPattern()
+			} else {
+				g.spawnObstacle()
+			}
+		}
+	}
+
+	every := coinEvery
+	if g.rushT > 0 {
+		every = rushCoinEvery
+	}
+	g.coinTimer += dt
+	if g.coinTimer >= every {
+		g.coinTimer -= every
+		g.spawnCoin()
+	}
+
+	// A closure that's due waits out a coin rush or a lane already burning,
+	// then goes as soon as it can
+	if g.cfg.closureEvery > 0 {
+		g.closureTimer += dt
+		if g.closureTimer >= g.cfg.closureEvery && g.rushT == 0 && !g.closure.active && g.startClosure() {
+			g.closureTimer = 0
+		}
+	}
+
+	// So does a gap, and it also waits for the track behind the horizon to
+	// clear
+	if g.cfg.gapEvery > 0 {
+		g.gapTimer += dt
+		if g.gapTimer >= g.cfg.gapEvery && g.rushT == 0 && !g.gap.active && g.startGap() {
+			g.gapTimer = 0
 		}
-	} else {
-		g.laneX = target
-		g.runnerLane = g.targetLane
 	}
 }
 
 func (g *game) spawnObstacle() {
+	if obstacles, coins := g.activeObjects(); obstacles+coins >= g.cfg.maxObjects {
+		return
+	}
 	for i := range g.obstacles {
 		if !g.obstacles[i].active {
+			// Half barriers, the rest split between low walls and high bars
+			kind := kindBarrier
+			switch g.rng.Intn(4) {
+			case 2:
+				kind = kindLow
+			case 3:
+				kind = kindHigh
+			}
+			// Move anything in the runner's lane at the very start to
+			// another lane, so the first obstacle is always reachable
+			lane := g.rng.Intn(numLanes)
+			if g.elapsed < fairStartSecs && lane == g.runnerLane {
+				lane = (lane + 1 + g.rng.Intn(numLanes-1)) % numLanes
+			}
 			g.obstacles[i] = obstacle{
-				lane:   rand.Intn(numLanes),
-				z:      float64(spawnZ),
+				lane:   lane,
+				kind:   kind,
+				z:      g.cfg.spawnZ,
 				active: true,
 			}
+			g.log.Info("spawn.obstacle", "t", g.elapsed, "lane", g.obstacles[i].lane, "kind", kind, "z", g.obstacles[i].z)
 			return
 		}
 	}
 }
 
 func (g *game) spawnCoin() {
-	lane := rand.Intn(numLanes)
-	for j := 0; j < 3; j++ {
+	// Coins back off early so there's always room for obstacles
+	if obstacles, coins := g.activeObjects(); obstacles+coins+coinsPerLine > g.cfg.maxObjects-obstacleReserve {
+		return
+	}
+	// Mostly straight lines, sometimes a diagonal you have to steer along
+	lane := g.rng.Intn(numLanes)
+	step := 0
+	if g.rng.Float64() < diagonalChance {
+		step = 1 - 2*g.rng.Intn(2)
+	}
+	gold := g.rng.Float64() < goldChance
+	lanes := coinLanes(lane, step)
+	g.log.Info("spawn.coins", "t", g.elapsed, "lane", lane, "step", step, "gold", gold, "z", g.cfg.spawnZ)
+	for j := 0; j < coinsPerLine; j++ {
 		for i := range g.coinPool {
 			if !g.coinPool[i].active {
 				g.coinPool[i] = coinObj{
-					lane:   lane,
-					z:      float64(spawnZ) + float64(j)*1.5,
+					lane:   lanes[j],
+					x:      float64(lanes[j]),
+					gold:   gold,
+					z:      g.cfg.spawnZ + float64(j)*g.cfg.coinSpacing,
 					active: true,
 				}
 				break
@@ -174,20 +557,186 @@ func (g *game) spawnCoin() {
 	}
 }
 
-func (g *game) autoDodge() {
+// coinLanes lays out a line of coins from lane, moving step lanes per coin
+// and bouncing off the edges of the track. A step of 0 is a straight line.
+func coinLanes(lane, step int) [coinsPerLine]int {
+	var lanes [coinsPerLine]int
+	for j := range lanes {
+		lanes[j] = lane
+		if next := lane + step; next < 0 || next >= numLanes {
+			step = -step
+		}
+		lane += step
+	}
+	return lanes
+}
+
+// activeObjects counts the obstacles and coins currently on the track.
+func (g *game) activeObjects() (obstacles, coins int) {
+	for i := range g.obstacles {
+		if g.obstacles[i].active {
+			obstacles++
+		}
+	}
+	for i := range g.coinPool {
+		if g.coinPool[i].active {
+			coins++
+		}
+	}
+	return obstacles, coins
+}
+
+// frozen reports whether the sim is holding still this tick.
+func (g *game) frozen() bool {
+	return g.paused || g.tooSmall() || g.ended()
+}
+
+// tooSmall reports whether the screen is too small to play on. The run waits
+// until it's resized back up, then carries on where it was. With -too-small
+// clamp the screen's never let get this small in the first place.
+func (g *game) tooSmall() bool {
+	return g.width < g.cfg.minW || g.height < g.cfg.minH
+}
+
+// playSize is the size to play at on a w by h terminal. That's the terminal's
+// size, unless -too-small clamp holds it up at -min-size.
+func (c config) playSize(w, h int) (int, int) {
+	if c.tooSmall != tooSmallClamp {
+		return w, h
+	}
+	return max(w, c.minW), max(h, c.minH)
+}
+
+// stepFor is how far the sim moves on after since has gone by since the last
+// frame: all of it, up to -max-step. See play for why.
+func (c config) stepFor(since time.Duration) float64 {
+	return min(since.Seconds(), c.maxStep)
+}
+
+// ended reports whether the run is over, for better or worse.
+func (g *game) ended() bool {
+	return g.finished || g.over
+}
+
+// crash handles the runner hitting an obstacle, according to the game-over
+// mode: hardcore ends the run, forgiving costs a life and practice just costs
+// points.
+func (g *game) crash() {
+	if !g.cfg.reducedMotion && !g.cfg.zen {
+		g.shake = shakeSecs
+	}
+
+	defer func() {
+		g.log.Info("crash", "t", g.elapsed, "lane", int(math.Round(g.laneX)), "mode", g.cfg.mode, "lives", g.lives, "over", g.over, "score", g.score)
+	}()
+
+	switch g.cfg.mode {
+	case modeHardcore:
+		g.over = true
+	case modeForgiving:
+		g.lives--
+		if g.lives <= 0 {
+			g.over = true
+		}
+	case modePractice:
+		// Down to 0 and no further, so a score's never negative
+		g.score = max(g.score-practiceHitCost, 0)
+	}
+}
+
+// waveFactor scales the obstacle spawn interval so runs alternate between calm
+// stretches and dense clusters. It only depends on elapsed time and the seeded
+// phase, so a seeded course plays out the same every time.
+func (g *game) waveFactor() float64 {
+	t := g.elapsed/g.cfg.wavePeriod*2*math.Pi + g.wavePhase
+	return 1 + g.cfg.waveAmplitude*math.Sin(t)
+}
+
+// inCoinReach reports whether a coin is close enough to grab this tick, going
+// by the configured z window and lane reach. A coin counts if it was anywhere
+// in the window during the tick, so big steps at high speed can't skip it.
+func (g *game) inCoinReach(c coinObj, dt float64) bool {
+	return g.inLaneReach(c.lane) && c.z < g.cfg.coinWindow && c.z+g.speed*dt > 0
+}
+
+// coinValue is what grabbing c right now is worth.
+func (g *game) coinValue(c coinObj) int {
+	value := g.cfg.coinValue
+	if c.gold {
+		value *= goldMultiplier
+	}
+	if g.inBonusZone() {
+		value *= 2
+	}
+	if g.superT > 0 {
+		value *= superMultiplier
+	}
+	return value
+}
+
+// inLaneReach reports whether coins in lane count for the runner.
+func (g *game) inLaneReach(lane int) bool {
+	lanes := lane - g.runnerLane
+	if lanes < 0 {
+		lanes = -lanes
+	}
+	return lanes <= g.cfg.coinLaneReach
+}
+
+// pullCoin slides a coin the runner can reach over toward them as it comes
+// in, so it's sitting on the runner by the time it's grabbed instead of
+// vanishing from the next lane. Coins out of reach stay in their lane.
+func (g *game) pullCoin(c *coinObj) {
+	pull := 0.0
+	if g.inLaneReach(c.lane) {
+		pull = (g.cfg.coinWindow + pullZ - c.z) / pullZ
+		pull = math.Max(0, math.Min(pull, 1))
+	}
+	// An overshoot can swing the runner off the edge, but not the coin
+	laneX := math.Max(0, math.Min(g.laneX, numLanes-1))
+	c.x = float64(c.lane) + (laneX-float64(c.lane))*pull
+}
+
+// inBonusZone reports whether the runner is inside a coin doubler zone.
+func (g *game) inBonusZone() bool {
+	return g.zone.active && g.zone.start <= runnerZ && g.zone.end > runnerZ
+}
+
+// laneDanger reports which lanes have a barrier or fire inside the dodge
+// lookahead, the things that can only be avoided by changing lanes.
+func (g *game) laneDanger() [numLanes]bool {
 	danger := [numLanes]bool{}
+	if g.closureAhead() {
+		danger[g.closure.lane] = true
+	}
 	for i := range g.obstacles {
-		if !g.obstacles[i].active {
+		if !g.obstacles[i].active || g.obstacles[i].kind != kindBarrier {
 			continue
 		}
-		if g.obstacles[i].z > 0 && g.obstacles[i].z < float64(dodgeLookahead) {
+		if g.obstacles[i].z > 0 && g.obstacles[i].z < g.cfg.lookahead {
 			danger[g.obstacles[i].lane] = true
 		}
 	}
+	return danger
+}
+
+// threatens reports whether obs can still hit the runner: it's in the lane
+// they're in or headed for, and hasn't gone by yet.
+func (g *game) threatens(obs *obstacle) bool {
+	return obs.z >= runnerZ && (obs.lane == g.runnerLane || obs.lane == g.targetLane)
+}
+
+// planDodge is what the autopilot does this tick: out of a lane with a train
+// coming, preferring one with coins, or else over or under whatever's coming
+// down the lane it's in. lane is where a move goes, -1 when there's nowhere to
+// go. It only decides, autoDodge carries it out.
+func (g *game) planDodge() (action, lane int, danger [numLanes]bool) {
+	danger = g.laneDanger()
 
 	cur := g.targetLane
 	if !danger[cur] {
-		return
+		// Staying put, but a low wall or high bar may still need handling
+		return g.planAct(), cur, danger
 	}
 
 	// Prefer lane with coins
@@ -199,115 +748,608 @@ func (g *game) autoDodge() {
 			}
 			// Check for coins in this lane
 			for i := range g.coinPool {
-				if g.coinPool[i].active && g.coinPool[i].lane == l && g.coinPool[i].z < float64(dodgeLookahead) {
+				if g.coinPool[i].active && g.coinPool[i].lane == l && g.coinPool[i].z < g.cfg.lookahead {
 					bestLane = l
 				}
 			}
 		}
 	}
-	if bestLane >= 0 {
-		g.targetLane = bestLane
+	if bestLane < 0 {
+		return pilotNone, bestLane, danger
+	}
+	return pilotMove, bestLane, danger
+}
+
+// autoDodge carries out the autopilot's plan for the tick, logging anything
+// it does so its decisions can be followed in -log.
+func (g *game) autoDodge() {
+	cur := g.targetLane
+	forGap := g.gapJump()
+	action, lane, danger := g.planDodge()
+	switch action {
+	case pilotMove:
+		g.steer(lane)
+	case pilotJump:
+		g.jump()
+	case pilotSlide:
+		g.slide()
+	}
+	if danger[cur] {
+		g.log.Info("dodge", "t", g.elapsed, "from", cur, "to", lane, "danger", danger[:])
+	}
+	if action != pilotNone {
+		g.log.Info("pilot", "t", g.elapsed, "action", pilotNames[action], "lane", g.targetLane, "gap", action == pilotJump && forGap)
 	}
 }
 
+// render draws the next frame. Only rows that changed since the last one are
+// sent, each with its own cursor move, since most of the sky and ground sits
+// still from one frame to the next.
 func (g *game) render() []byte {
 	g.frame = g.frame[:0]
 
-	// Move cursor home
-	g.frame = append(g.frame, "\033[H"...)
+	// Nothing fits in a zero-sized terminal, and the geometry below assumes
+	// at least a cell to work with
+	if g.width < 1 || g.height < 1 {
+		return g.frame
+	}
 
 	horizon := g.height / 3
-	trackLeft := (g.width - trackWidth) / 2
+	trackLeft := (g.width - g.trackCols()) / 2
 
-	for row := 0; row < g.height; row++ {
+	// Overlays that are the same for every row get worked out once a frame
+	g.hud, g.hudBar = nil, ""
+	if !g.cfg.zen {
+		g.hud = g.hudRows()
+		if g.cfg.hudPos == hudBottom {
+			g.hudBar = squeezeHUD(g.hud, g.width)
+		}
+	}
+	g.tunePanel = g.tuneLines()
+	g.summary = nil
+	if g.ended() {
+		// The share card goes under the panel, if there's room
+		g.summary = g.summaryLines()
+		if card := g.shareCard(g.width); card != nil && len(g.summary)+1+len(card) <= g.height-2 {
+			g.summary = append(append(g.summary, ""), card...)
+		}
+	}
+
+	rows := g.shownRows()
+	if len(g.screen) != rows*g.width {
+		g.screen = make([]byte, rows*g.width)
+		g.redraw = true
+	}
+	shake := g.shakeOffset()
+	px, text := g.palette()
+	for row := 0; row < rows; row++ {
 		line := g.renderRow(row, horizon, trackLeft)
-		g.frame = append(g.frame, line...)
-		if row < g.height-1 {
-			g.frame = append(g.frame, "\r\n"...)
+		if shake != 0 {
+			shiftRow(line, shake)
+		}
+		if g.cfg.debugRender {
+			checkRow(line, row, g.width, g.cfg.halfBlock)
+		}
+		if g.clipW > 0 && g.clipW < len(line) {
+			line = line[:g.clipW]
+		}
+		was := g.screen[row*g.width : row*g.width+len(line)]
+		if !g.redraw && bytes.Equal(was, line) {
+			continue
+		}
+		copy(was, line)
+		g.frame = append(g.frame, "\033["...)
+		g.frame = strconv.AppendInt(g.frame, int64(g.top+row+1), 10)
+		g.frame = append(g.frame, ";1H"...)
+		if g.cfg.zen {
+			g.frame = append(g.frame, zenText...)
+		}
+		if g.cfg.halfBlock {
+			g.frame = appendHalfBlock(g.frame, line, px, text)
+		} else {
+			g.frame = append(g.frame, line...)
 		}
+		if g.cfg.zen {
+			g.frame = append(g.frame, plainText...)
+		}
+	}
+	g.redraw = false
+	if g.cfg.debugRender {
+		g.checkFrame()
 	}
 
 	return g.frame
 }
 
-func (g *game) renderRow(row, horizon, trackLeft int) string {
-	buf := make([]byte, g.width)
+// shownRows is how many of the game's rows make it onto the terminal.
+func (g *game) shownRows() int {
+	if g.clipH > 0 && g.clipH < g.height {
+		return g.clipH
+	}
+	return g.height
+}
+
+// clearScreen is what blanks the screen for the game. Inline it's only the
+// game's rows, so what's above them is kept.
+func (g *game) clearScreen() string {
+	if !g.cfg.noAltScreen {
+		return "\033[2J"
+	}
+	return fmt.Sprintf("\033[%d;1H\033[J", g.top+1)
+}
+
+// renderRow draws a row into the shared row buffer, so the result is only
+// good until the next call.
+func (g *game) renderRow(row, horizon, trackLeft int) []byte {
+	if cap(g.rowBuf) < g.width {
+		g.rowBuf = make([]byte, g.width)
+	}
+	buf := g.rowBuf[:g.width]
 	for i := range buf {
 		buf[i] = ' '
 	}
 
-	if row < horizon {
+	switch {
+	case g.cfg.view == viewTopDown:
+		g.drawTopDown(buf, row)
+	case row < horizon:
 		// Sky
 		g.drawSky(buf, row, horizon)
-	} else {
+	case g.cfg.halfBlock:
+		g.drawGroundHalf(buf, row, horizon)
+	default:
 		// Ground with perspective track
 		g.drawGround(buf, row, horizon, trackLeft)
 	}
 
-	// HUD on first two rows
-	if row == 0 {
-		hud := fmt.Sprintf(" SCORE: %07d ", g.score)
-		placeString(buf, g.width-len(hud)-1, hud)
+	// Coin values floating up off the runner
+	if !g.cfg.zen {
+		g.drawPopups(buf, row, horizon)
+	}
+
+	// HUD down a top corner, or along the bottom
+	hudTop, hudRows := g.hudSpan()
+	if i := row - hudTop; i >= 0 && i < hudRows {
+		if g.cfg.hudPos == hudTopLeft {
+			placeString(buf, 1, pickHUD(len(buf), g.hud[i]...))
+		} else {
+			placeHUD(buf, g.hud[i]...)
+		}
+	}
+	if g.cfg.hudPos == hudBottom && row == g.height-1 {
+		placeString(buf, max((g.width-len(g.hudBar))/2, 0), g.hudBar)
+	}
+
+	// Big countdown digits, then GO! for a moment
+	if big := g.countdownText(); big != nil {
+		top := g.height/2 - len(big)/2
+		if i := row - top; i >= 0 && i < len(big) {
+			placeString(buf, (g.width-len(big[i]))/2, big[i])
+		}
+	}
+
+	// Coin rush banner, counting down to when obstacles come back
+	if g.rushT > 0 && !g.ended() && !g.cfg.zen && row == g.height/4 {
+		msg := fmt.Sprintf(" COIN RUSH! %d ", int(math.Ceil(g.rushT)))
+		placeString(buf, (g.width-len(msg))/2, msg)
+	}
+
+	// Lane gauge along the bottom, just above a bottom HUD, then the controls
+	// above that
+	hintRow := g.height - 1
+	if g.cfg.hudPos == hudBottom {
+		hintRow--
+	}
+	if g.cfg.laneMarker {
+		if row == hintRow {
+			marker := g.laneMarkerLine()
+			placeString(buf, (g.width-len(marker))/2, marker)
+		}
+		hintRow--
+	}
+	if g.hintsOn && row == hintRow {
+		hint := g.cfg.controlsHint()
+		placeString(buf, (g.width-len(hint))/2, hint)
+	}
+
+	if g.tooSmall() && !g.ended() && row == g.height/2 {
+		msg := " resize to continue "
+		if len(msg) > g.width {
+			msg = "resize"
+		}
+		placeString(buf, (g.width-len(msg))/2, msg)
+	} else if g.paused && !g.ended() && row == g.height/2 {
+		msg := " PAUSED - press p to resume "
+		placeString(buf, (g.width-len(msg))/2, msg)
+	}
+
+	// Level complete or game over panel in the middle of the screen
+	if g.summary != nil {
+		top := (g.height - len(g.summary)) / 2
+		if i := row - top; i >= 0 && i < len(g.summary) {
+			placeString(buf, (g.width-len(g.summary[i]))/2, g.summary[i])
+		}
+	}
+
+	// Tuning panel in the bottom-left corner
+	if top := g.height - 1 - len(g.tunePanel); row >= top && row < g.height-1 {
+		placeString(buf, 1, g.tunePanel[row-top])
+	}
+
+	// Lane debug block in the top-left corner
+	if g.cfg.debugLanes && row <= numLanes {
+		placeString(buf, 1, g.debugLaneLine(row))
+	}
+
+	return buf
+}
+
+var bigGlyphs = map[int][]string{
+	3: {
+		"#### ",
+		"    #",
+		" ### ",
+		"    #",
+		"#### ",
+	},
+	2: {
+		"#### ",
+		"    #",
+		" ### ",
+		"#    ",
+		"#####",
+	},
+	1: {
+		"  #  ",
+		" ##  ",
+		"  #  ",
+		"  #  ",
+		" ### ",
+	},
+	0: {
+		" ###   ###  # ",
+		"#     #   # # ",
+		"# ##  #   # # ",
+		"#  #  #   #   ",
+		" ###   ###  # ",
+	},
+}
+
+// countdownText is the big text for the countdown overlay, or nil once it's
+// done. The 0 glyph is GO!
+func (g *game) countdownText() []string {
+	if g.countdown > 0 {
+		return bigGlyphs[int(math.Ceil(g.countdown))]
+	}
+	if g.elapsed < goSecs && !g.ended() {
+		return bigGlyphs[0]
+	}
+	return nil
+}
+
+// hudRows is the HUD, one row per entry, each with shorter variants to fall
+// back on when the terminal is narrow.
+func (g *game) hudRows() [][]string {
+	if g.cfg.hudLayout != nil {
+		return g.layoutRows()
+	}
+	bonus := ""
+	if g.inBonusZone() {
+		bonus = " x2"
+	}
+	rows := [][]string{
+		{
+			fmt.Sprintf(" SCORE: %s ", g.cfg.scoreText(g.score, true)),
+			fmt.Sprintf(" S:%s ", g.cfg.scoreText(g.score, false)),
+			g.cfg.scoreText(g.score, false),
+		},
+		{
+			fmt.Sprintf(" COINS: %d%s ", g.coins, bonus),
+			fmt.Sprintf(" C:%d%s ", g.coins, bonus),
+			fmt.Sprintf("%d", g.coins),
+		},
+	}
+
+	// Combo meter, longer on wider terminals
+	barW := min(max(g.width/8, 4), 16)
+	label, short := "COMBO", "CB"
+	if g.superT > 0 {
+		label, short = fmt.Sprintf("SUPER x%d", superMultiplier), fmt.Sprintf("x%d", superMultiplier)
+	}
+	level := g.comboLevel()
+	rows = append(rows, []string{
+		fmt.Sprintf(" %s [%s] ", label, meterBar(level, barW)),
+		fmt.Sprintf(" %s [%s] ", short, meterBar(level, barW/2)),
+		"[" + meterBar(level, 3) + "]",
+	})
+
+	// Speedometer, ticked off in quarters so you can see each one go by
+	if g.cfg.speedometer && g.width >= speedoMinWidth {
+		bar := []byte(meterBar(g.speedLevel(), barW))
+		for q := 1; q < 4; q++ {
+			if i := barW * q / 4; bar[i] == ' ' {
+				bar[i] = '|'
+			}
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf(" SPD %4.1f [%s] ", g.speed, bar),
+			fmt.Sprintf(" [%s] ", bar),
+		})
+	}
+
+	switch g.cfg.mode {
+	case modeForgiving:
+		rows = append(rows, []string{
+			fmt.Sprintf(" LIVES: %s ", strings.Repeat("<3 ", g.lives)),
+			fmt.Sprintf(" LIVES: %d ", g.lives),
+			fmt.Sprintf(" L:%d ", g.lives),
+		})
+	case modeHardcore:
+		rows = append(rows, []string{" HARDCORE ", " HC "})
+	case modePractice:
+		rows = append(rows, []string{" PRACTICE ", " PR "})
+	}
+
+	if g.cfg.handicap {
+		rows = append(rows, []string{
+			fmt.Sprintf(" HANDICAP: SPD %g PTS %d ", g.cfg.baseSpeed, g.cfg.startScore),
+			" HANDICAP ",
+			" HCP ",
+		})
+	}
+
+	if g.cfg.challenge {
+		rows = append(rows, []string{
+			fmt.Sprintf(" TRY %d  BEST: %s ", g.attempt, g.cfg.scoreText(g.bestScore, true)),
+			fmt.Sprintf(" T%d B:%s ", g.attempt, g.cfg.scoreText(g.bestScore, false)),
+			fmt.Sprintf(" T%d ", g.attempt),
+		})
+	}
+
+	if g.cfg.learn {
+		rows = append(rows, []string{" LEARNING - NOT RANKED ", " LEARNING ", " LRN "})
+	}
+
+	if g.cfg.seeded {
+		rows = append(rows, []string{
+			fmt.Sprintf(" SEED BEST: %s ", g.cfg.scoreText(g.seedBest, true)),
+			fmt.Sprintf(" B:%s ", g.cfg.scoreText(g.seedBest, false)),
+			g.cfg.scoreText(g.seedBest, false),
+		})
+		if gap, ok := g.paceGap(); ok {
+			rows = append(rows, g.paceRow(gap))
+		}
+	}
+	return rows
+}
+
+// speedLevel is how far the speedometer reads, 0 at the start speed to 1 at
+// the cap. Uncapped runs peg it after speedoSecs.
+func (g *game) speedLevel() float64 {
+	top := g.cfg.maxSpeed
+	if top == 0 {
+		top = g.cfg.baseSpeed + g.cfg.speedRamp*speedoSecs
+	}
+	if top <= g.cfg.baseSpeed {
+		return 1
+	}
+	return math.Max(0, math.Min((g.speed-g.cfg.baseSpeed)/(top-g.cfg.baseSpeed), 1))
+}
+
+// topSpeedLine is the run's top speed for the summary, next to the fastest
+// ever, or saying it's a new one if it counts.
+func (g *game) topSpeedLine() string {
+	line := fmt.Sprintf("TOP SPEED %.1f", g.topSpeed)
+	if _, ranked := seedKey(g.cfg, g.seed); ranked && g.topSpeed > g.bestSpeed {
+		return line + " (NEW BEST)"
+	}
+	if g.bestSpeed > 0 {
+		line += fmt.Sprintf(" (BEST %.1f)", g.bestSpeed)
+	}
+	return line
+}
+
+// summaryLines is the boxed end of run panel.
+func (g *game) summaryLines() []string {
+	title := "LEVEL COMPLETE"
+	if g.over {
+		title = "GAME OVER"
+	}
+	body := []string{
+		title,
+		"",
+		"SCORE " + g.cfg.scoreText(g.score, false),
+		fmt.Sprintf("COINS %d", g.coins),
+		fmt.Sprintf("TIME  %.1fs", g.elapsed),
+		g.topSpeedLine(),
+		fmt.Sprintf("SEED  %d", g.seed),
+	}
+	if g.cfg.handicap {
+		body = append(body,
+			"",
+			fmt.Sprintf("STARTED AT SPEED %g", g.cfg.baseSpeed),
+			fmt.Sprintf("WITH %d POINTS, %d LIVES", g.cfg.startScore, g.cfg.lives))
+	}
+	switch {
+	case g.cfg.learn:
+		body = append(body, "", "LEARNING RUN, NOT RANKED")
+	case g.cfg.tuned:
+		body = append(body, "", "TUNED RUN, NOT RANKED")
+	}
+	if hint := g.cfg.quitHint(); hint != "" {
+		body = append(body, "", hint)
+	}
+	inner := 0
+	for _, l := range body {
+		if len(l) > inner {
+			inner = len(l)
+		}
+	}
+
+	border := "+" + strings.Repeat("-", inner+4) + "+"
+	lines := []string{border}
+	for _, l := range body {
+		pad := inner - len(l)
+		lines = append(lines, "|  "+strings.Repeat(" ", pad/2)+l+strings.Repeat(" ", pad-pad/2)+"  |")
+	}
+	return append(lines, border)
+}
+
+// debugLaneLine returns one line of the lane overlay: a line per lane with the
+// nearest obstacle and whether autoDodge sees it as dangerous, then the
+// runner's current and target lane.
+func (g *game) debugLaneLine(row int) string {
+	if row == numLanes {
+		return fmt.Sprintf(" RUN %d>%d ", g.runnerLane, g.targetLane)
+	}
+
+	nearest := -1.0
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		if !obs.active || obs.lane != row || obs.z < 0 {
+			continue
+		}
+		if nearest < 0 || obs.z < nearest {
+			nearest = obs.z
+		}
+	}
+
+	z := "  --"
+	if nearest >= 0 {
+		z = fmt.Sprintf("%4.1f", nearest)
+	}
+	flag := " "
+	if g.laneDanger()[row] {
+		flag = "!"
 	}
-	if row == 1 {
-		hud := fmt.Sprintf(" COINS: %d ", g.coins)
-		placeString(buf, g.width-len(hud)-1, hud)
+	return fmt.Sprintf(" L%d %s %s ", row, z, flag)
+}
+
+// laneTies is what each lane's cross-ties are drawn with.
+var laneTies = [numLanes]byte{'-', '~', '-'}
+
+var (
+	sunGlyph  = []string{` \|/ `, `-(O)-`, ` /|\ `}
+	moonGlyph = []string{` .-'`, `(   `, ` '-.`}
+)
+
+// skyBody places the sun or moon for this point in the day/night cycle. Each
+// rises on the left, arcs over and sets on the right, staying under the HUD.
+// It reports false when the sky is too short to fit one.
+func (g *game) skyBody(horizon int) (body []string, x, y int, ok bool) {
+	body = sunGlyph
+	cycle := math.Mod(g.elapsed, 2*daySecs) / daySecs
+	if cycle >= 1 {
+		body = moonGlyph
+		cycle--
 	}
 
-	return string(buf)
+	hudTop, hudRows := g.hudSpan()
+	top, bottom := hudTop+hudRows, horizon-1-len(body)
+	if bottom < top {
+		return nil, 0, 0, false
+	}
+	x = int(cycle*float64(g.width+len(body[0]))) - len(body[0])
+	y = bottom - int(math.Sin(cycle*math.Pi)*float64(bottom-top))
+	return body, x, y, true
 }
 
 func (g *game) drawSky(buf []byte, row, horizon int) {
+	if len(buf) == 0 {
+		return
+	}
+
 	// Simple sky with stars
-	if row%3 == 0 {
+	if g.cfg.skyEvery > 0 && row%g.cfg.skyEvery == 0 {
 		pos := (row*17 + 11) % len(buf)
 		if pos >= 0 && pos < len(buf) {
-			buf[pos] = '.'
+			buf[pos] = g.cfg.skyChar[0]
 		}
 		pos2 := (row*31 + 7) % len(buf)
 		if pos2 >= 0 && pos2 < len(buf) {
-			buf[pos2] = '.'
+			buf[pos2] = g.cfg.skyChar[0]
+		}
+	}
+	// Sun by day, moon by night
+	if body, x, y, ok := g.skyBody(horizon); ok {
+		if i := row - y; i >= 0 && i < len(body) {
+			placeString(buf, x, body[i])
 		}
 	}
-	// Horizon line
+
+	// Horizon line, marking the lanes with coins on the way
 	if row == horizon-1 {
 		for i := range buf {
 			buf[i] = '_'
 		}
+		if g.cfg.coinMarkers {
+			center := g.width/2 + g.curveShift(g.cfg.farZ)
+			for lane, ahead := range g.coinLanesAhead() {
+				if x := center + (lane-1)*2; ahead && x >= 0 && x < len(buf) {
+					buf[x] = '.'
+				}
+			}
+		}
+	}
+}
+
+// coinLanesAhead reports which lanes have coins coming that are still too far
+// off for the track to draw.
+func (g *game) coinLanesAhead() (lanes [numLanes]bool) {
+	fullTw := float64(g.trackCols())
+	for i := range g.coinPool {
+		cn := &g.coinPool[i]
+		if cn.active && int(fullTw*(1-cn.z/g.cfg.farZ)) < 3 {
+			lanes[cn.lane] = true
+		}
+	}
+	return lanes
+}
+
+// fillGround scatters the ground texture across buf, shifted along by off so
+// each row's specks sit on a diagonal from the last.
+func (g *game) fillGround(buf []byte, off int) {
+	if g.cfg.groundEvery == 0 {
+		return
+	}
+	for i := range buf {
+		if (i+off)%g.cfg.groundEvery == 0 {
+			buf[i] = g.cfg.groundChar[0]
+		}
 	}
 }
 
 func (g *game) drawGround(buf []byte, row, horizon, trackLeft int) {
 	// Perspective: track narrows toward horizon
-	depth := float64(row-horizon) / float64(g.height-horizon)
+	span := g.height - horizon
+	if span <= 0 || len(buf) == 0 {
+		return
+	}
+	depth := float64(row-horizon) / float64(span)
 	if depth <= 0 {
 		return
 	}
 
 	// Track width scales with depth
-	tw := int(float64(trackWidth) * depth)
+	fullTw := float64(g.trackCols())
+	tw := int(fullTw * depth)
 	if tw < 3 {
 		tw = 3
 	}
-	center := g.width / 2
+	center := g.width/2 + g.curveShift((1-depth)*g.cfg.farZ)
 	left := center - tw/2
 	right := center + tw/2
-	if left < 0 {
-		left = 0
-	}
-	if right >= g.width {
-		right = g.width - 1
-	}
+
+	// left and right are where the rails really are, even off screen, so
+	// lanes line up with what's in them. Only the columns between in0 and
+	// in1 get drawn on, the inside of the track that's on screen
+	in0, in1 := max(left+1, 0), min(right, g.width)
 
 	// Ground texture outside track
-	for i := range buf {
-		if (i+row)%5 == 0 {
-			buf[i] = '.'
-		}
-	}
+	g.fillGround(buf, row)
 
 	// Track surface
-	for x := left; x <= right; x++ {
+	for x := max(left, 0); x <= min(right, g.width-1); x++ {
 		buf[x] = ' '
 	}
 
@@ -319,25 +1361,70 @@ func (g *game) drawGround(buf []byte, row, horizon, trackLeft int) {
 		buf[right] = '|'
 	}
 
-	// Lane dividers
+	// Lane dividers, dollar signs inside a bonus zone
+	zoneTop, zoneBottom := g.zRow(g.zone.end, horizon), g.zRow(g.zone.start, horizon)
+	inZone := g.zone.active && row >= zoneTop && row <= zoneBottom
+	divider := byte(':')
+	if inZone {
+		divider = '$'
+	}
 	lw := float64(tw) / float64(numLanes)
 	for l := 1; l < numLanes; l++ {
 		dx := left + int(float64(l)*lw)
-		if dx > left && dx < right && dx < g.width {
+		if dx >= in0 && dx < in1 {
 			// Dashed line
 			scrollRow := int(g.scrollOff*2) + row
 			if scrollRow%3 != 0 {
-				buf[dx] = ':'
+				buf[dx] = divider
 			}
 		}
 	}
 
-	// Cross-ties
+	// Cross-ties, wavy in the middle lane so it's easy to tell which lane
+	// is which at a glance, even round a bend
 	scrollRow := float64(row) + g.scrollOff*3
 	if int(scrollRow)%4 == 0 {
-		for x := left + 1; x < right; x++ {
+		for x := in0; x < in1; x++ {
 			if buf[x] == ' ' {
-				buf[x] = '-'
+				buf[x] = laneTies[min(int(float64(x-left)/lw), numLanes-1)]
+			}
+		}
+	}
+
+	// Bonus zone start and end lines
+	if g.zone.active && ((g.zone.start >= 0 && row == zoneBottom) || (g.zone.end <= g.cfg.farZ && row == zoneTop)) {
+		for x := in0; x < in1; x++ {
+			buf[x] = '='
+		}
+	}
+
+	// Fire down a closed lane, between the dividers
+	if c := g.closure; c.active && row >= g.zRow(min(c.end, g.cfg.farZ), horizon) && row <= g.zRow(max(c.start, 0), horizon) {
+		for x := max(left+int(float64(c.lane)*lw)+1, in0); x < min(left+int(float64(c.lane+1)*lw), in1); x++ {
+			buf[x] = g.fireGlyph(x, row)
+		}
+	}
+
+	// No ground at all across a gap, rails included
+	if p := g.gap; p.active && row >= g.zRow(min(p.end, g.cfg.farZ), horizon) && row <= g.zRow(max(p.start, 0), horizon) {
+		for x := max(left, 0); x <= min(right, g.width-1); x++ {
+			buf[x] = ' '
+		}
+	}
+
+	// Checkered finish line coming up in level mode
+	if g.cfg.levelLength > 0 {
+		finishZ := g.cfg.levelLength - g.distance
+		if finishZ >= 0 && finishZ <= g.cfg.farZ {
+			finishRow := g.zRow(finishZ, horizon)
+			if row >= finishRow-1 && row <= finishRow {
+				for x := in0; x < in1; x++ {
+					if (x+row)%2 == 0 {
+						buf[x] = '#'
+					} else {
+						buf[x] = ' '
+					}
+				}
 			}
 		}
 	}
@@ -348,30 +1435,59 @@ func (g *game) drawGround(buf []byte, row, horizon, trackLeft int) {
 		if !obs.active || obs.z < 0.5 {
 			continue
 		}
-		obsDepth := 1.0 - obs.z/float64(farZ)
+		obsDepth := 1.0 - obs.z/g.cfg.farZ
 		if obsDepth < 0 || obsDepth > 1 {
 			continue
 		}
+		// New arrivals pop in, growing from a dot to full size. Nothing's
+		// drawn on the horizon or over it into the sky, so one right out there
+		// is cut down to the ground rows
+		pop := g.obstaclePop(obs)
 		obsRow := horizon + int(obsDepth*float64(g.height-horizon))
-		if row >= obsRow-2 && row <= obsRow {
-			obsTw := int(float64(trackWidth) * (1.0 - obs.z/float64(farZ)))
-			if obsTw < 3 {
+		obsTop := max(obsRow-int(math.Round(2*pop)), horizon+1)
+		if row >= obsTop && row <= obsRow {
+			obsTw := g.obstacleCols(obs.z)
+			if obsTw < minObstacleCols {
+				// Too far off to have a shape, a speck in its lane
+				if row == obsRow {
+					x := g.width/2 + g.curveShift(obs.z) - minObstacleCols/2 + obs.lane*minObstacleCols/numLanes
+					if x >= 0 && x < g.width {
+						buf[x] = '.'
+					}
+				}
 				continue
 			}
-			obsLeft := center - obsTw/2
+			obsLeft := g.width/2 + g.curveShift(obs.z) - obsTw/2
 			obsLW := float64(obsTw) / float64(numLanes)
-			ox := obsLeft + int(float64(obs.lane)*obsLW+obsLW*0.15)
-			ow := int(obsLW * 0.7)
+			obsW := obsLW * 0.7 * pop
+			ox := obsLeft + int(float64(obs.lane)*obsLW+(obsLW-obsW)/2)
+			ow := int(obsW)
 			if ow < 1 {
 				ow = 1
 			}
-			for x := ox; x < ox+ow && x < g.width; x++ {
-				if x >= 0 {
-					if row == obsRow-2 {
-						buf[x] = '#'
-					} else {
+			// Only the part that's on screen is drawn, but the edges stay
+			// where they really are, so one cut off by the side of the
+			// screen has no outline or post down that side
+			hollow := !g.threatens(obs)
+			for x := max(ox, 0); x < min(ox+ow, g.width); x++ {
+				switch obs.kind {
+				case kindBarrier:
+					// Just the outline once it can't reach the runner
+					if !hollow || row == obsTop || row == obsRow || x == ox || x == ox+ow-1 {
 						buf[x] = '#'
 					}
+				case kindLow:
+					// Spikes along the ground
+					if row == obsRow {
+						buf[x] = '^'
+					}
+				case kindHigh:
+					// A bar up top on two posts
+					if row == obsTop {
+						buf[x] = '='
+					} else if x == ox || x == ox+ow-1 {
+						buf[x] = '|'
+					}
 				}
 			}
 		}
@@ -383,50 +1499,366 @@ func (g *game) drawGround(buf []byte, row, horizon, trackLeft int) {
 		if !cn.active || cn.z < 0.5 {
 			continue
 		}
-		coinDepth := 1.0 - cn.z/float64(farZ)
+		coinDepth := 1.0 - cn.z/g.cfg.farZ
 		if coinDepth < 0 || coinDepth > 1 {
 			continue
 		}
 		coinRow := horizon + int(coinDepth*float64(g.height-horizon))
 		if row == coinRow {
-			cnTw := int(float64(trackWidth) * (1.0 - cn.z/float64(farZ)))
+			cnTw := int(fullTw * (1.0 - cn.z/g.cfg.farZ))
 			if cnTw < 3 {
 				continue
 			}
-			cnLeft := center - cnTw/2
+			cnLeft := g.width/2 + g.curveShift(cn.z) - cnTw/2
 			cnLW := float64(cnTw) / float64(numLanes)
-			cx := cnLeft + int(float64(cn.lane)*cnLW+cnLW*0.5)
-			if cx >= 0 && cx < g.width {
-				buf[cx] = 'o'
+			cx := cnLeft + int(g.snap(cn.x)*cnLW+cnLW*0.5)
+			if cx < 0 || cx >= g.width {
+				continue
+			}
+
+			// Sized by depth like everything else: a speck far off, then
+			// the coin, then bracketed up close, as long as the brackets
+			// have bare track to go on and won't run into the runner
+			switch {
+			case coinDepth < coinFarDepth && !cn.gold:
+				buf[cx] = '.'
+			case coinDepth >= coinNearDepth && cnLW >= coinNearCols && !g.nearRunner(cx, row, horizon):
+				buf[cx] = g.coinGlyph(i)
+				if cx > 0 && cx < g.width-1 && bareTrack(buf[cx-1]) && bareTrack(buf[cx+1]) {
+					buf[cx-1], buf[cx+1] = '(', ')'
+				}
+			default:
+				buf[cx] = g.coinGlyph(i)
 			}
 		}
 	}
 
-	// Draw runner
-	runnerDepth := 0.85 // near bottom
-	runnerScreenRow := horizon + int(runnerDepth*float64(g.height-horizon))
-	rTw := int(float64(trackWidth) * runnerDepth)
-	rLeft := center - rTw/2
+	g.drawTimings(buf, row, horizon)
+	g.drawRunner(buf, row, horizon)
+}
+
+// runnerSpot is the column the middle of the runner's on and the row their
+// feet are on when they're not jumping, in the perspective view.
+func (g *game) runnerSpot(horizon int) (x, row int) {
+	rTw := int(float64(g.trackCols()) * runnerDepth)
+	rLeft := g.width/2 + g.curveShift((1-runnerDepth)*g.cfg.farZ) - rTw/2
 	rLW := float64(rTw) / float64(numLanes)
-	rx := rLeft + int(g.laneX*rLW+rLW*0.5)
+	return rLeft + int(g.snap(g.laneX)*rLW+rLW*0.5), horizon + int(runnerDepth*float64(g.height-horizon))
+}
+
+// nearRunner reports whether column x of a row is right up against the
+// runner, trail included, where anything extra drawn would run into them.
+func (g *game) nearRunner(x, row, horizon int) bool {
+	rx, feet := g.runnerSpot(horizon)
+	top, bottom := feet-g.jumpLift()-2, feet+g.trailLength()
+	return row >= top && row <= bottom && x >= rx-2 && x <= rx+2
+}
 
-	// Runner is 3 rows tall
-	if row == runnerScreenRow-2 {
+// obstaclePop is how far an obstacle has grown since it popped in, 0 to 1.
+func (g *game) obstaclePop(obs *obstacle) float64 {
+	if g.cfg.reducedMotion || g.cfg.retro {
+		return 1
+	}
+	return math.Min(obs.shown/popSecs, 1)
+}
+
+// drawRunner draws the runner, and their speed lines, on a ground row of the
+// perspective view.
+func (g *game) drawRunner(buf []byte, row, horizon int) {
+	rx, runnerScreenRow := g.runnerSpot(horizon)
+
+	// Lean into lane changes: the torso tilts as soon as we're moving and
+	// the head follows when there's a way to go
+	lean := 0
+	body := "/|\\"
+	diff := float64(g.targetLane) - g.laneX
+	if g.cfg.retro {
+		diff = 0 // bolt upright, always
+	}
+	if diff > 0.05 {
+		body = "//\\"
+		if diff > 0.3 {
+			lean = 1
+		}
+	} else if diff < -0.05 {
+		body = "/\\\\"
+		if diff < -0.3 {
+			lean = -1
+		}
+	}
+
+	// Jumps lift the whole runner along an arc
+	feet := runnerScreenRow - g.jumpLift()
+
+	// Runner is 3 rows tall, or lying flat on the bottom row while sliding
+	if g.sliding() {
+		if row == runnerScreenRow {
+			placeStringBytes(buf, rx-1, []byte("_O_"))
+		}
+	} else if row == feet-2 {
 		// Head
-		if rx >= 0 && rx < g.width {
-			buf[rx] = 'O'
+		if hx := rx + lean; hx >= 0 && hx < g.width {
+			buf[hx] = 'O'
 		}
-	} else if row == runnerScreenRow-1 {
+	} else if row == feet-1 {
 		// Body
-		placeStringBytes(buf, rx-1, []byte("/|\\"))
-	} else if row == runnerScreenRow {
-		// Legs - walking animation
-		frame := int(g.elapsed*8) % 4
+		placeStringBytes(buf, rx-1, []byte(body))
+	} else if row == feet {
+		// Legs - walking animation, tucked in the air
+		frame := int(g.stride) % 4
 		legs := [4]string{"/ \\", "| |", "\\ /", "| |"}
+		if g.airborne() {
+			frame = 0
+		}
 		placeStringBytes(buf, rx-1, []byte(legs[frame]))
+	} else if !g.cfg.reducedMotion && row > runnerScreenRow && row <= runnerScreenRow+g.trailLength() {
+		// Speed lines trailing behind the runner, flickering as they go
+		streak := "' '"
+		if (int(g.elapsed*16)+row)%2 == 0 {
+			streak = ". ."
+		}
+		placeStringBytes(buf, rx-1, []byte(streak))
+	}
+}
+
+// laneMarkerLine is the lane gauge, a slot per lane with the lane the runner's
+// headed for in brackets and a ^ that slides across as it gets there. It sits
+// flat along the bottom, so it reads the same whatever the perspective does.
+func (g *game) laneMarkerLine() string {
+	const slot = 4 // columns per lane slot, counting one divider
+	line := []byte(strings.Repeat("|   ", numLanes) + "|")
+	line[g.targetLane*slot], line[(g.targetLane+1)*slot] = '[', ']'
+	line[slot/2+int(math.Round(g.snap(g.laneX)*slot))] = '^'
+	return " " + string(line) + " "
+}
+
+// snap rounds a lane position to a whole lane for -retro, where nothing's
+// drawn partway between lanes.
+func (g *game) snap(x float64) float64 {
+	if g.cfg.retro {
+		return math.Round(x)
+	}
+	return x
+}
+
+// bareTrack reports whether c is open track in the perspective view, with
+// nothing but a divider or tie on it.
+func bareTrack(c byte) bool {
+	return c == ' ' || c == ':' || c == '$' || bytes.IndexByte(laneTies[:], c) >= 0
+}
+
+// coinGlyph is how the coin in pool slot i looks this frame. Coins spin, each
+// a little out of step with the next so they don't turn in unison. Gold coins
+// are a solid @.
+func (g *game) coinGlyph(i int) byte {
+	const spin = "oO0|"
+	if g.coinPool[i].gold {
+		return '@'
+	}
+	if g.cfg.reducedMotion {
+		return spin[0]
+	}
+	return spin[(int(g.elapsed*8)+i)%len(spin)]
+}
+
+// trailLength is how many rows of speed lines to draw behind the runner. The
+// trail kicks in once the run picks up pace and grows with speed.
+func (g *game) trailLength() int {
+	n := int((g.speed - 8) / 3)
+	if n < 0 {
+		return 0
+	}
+	if n > 3 {
+		return 3
+	}
+	return n
+}
+
+// obstacleCols is how wide an obstacle's stretch of track is at z in the
+// perspective view. Below minObstacleCols it's too far off to draw.
+func (g *game) obstacleCols(z float64) int {
+	return int(float64(g.trackCols()) * (1 - z/g.cfg.farZ))
+}
+
+// trackCols is the track's width at the bottom of the screen. By default it's
+// the classic fixed width, or with -track-width a share of the terminal so
+// wide screens get a wide track. It never drops below the classic width.
+func (g *game) trackCols() int {
+	if g.cfg.trackScale <= 0 {
+		return trackWidth
+	}
+	tw := int(float64(g.width) * g.cfg.trackScale)
+	if tw < trackWidth {
+		return trackWidth
+	}
+	return tw
+}
+
+// curveShift is how many columns the track's center is pushed sideways at z.
+// Bends barely move things near the runner and swing the far end the most.
+func (g *game) curveShift(z float64) int {
+	d := z / g.cfg.farZ
+	return int(math.Round(g.curve * d * d * curveCols))
+}
+
+// zRow is the screen row an object at z sits on.
+func (g *game) zRow(z float64, horizon int) int {
+	return horizon + int((1.0-z/g.cfg.farZ)*float64(g.height-horizon))
+}
+
+// shakeOffset is how many columns the camera is knocked sideways this frame.
+// It flips side to side and dies down as the shake runs out.
+func (g *game) shakeOffset() int {
+	if g.shake <= 0 {
+		return 0
+	}
+	off := int(shakeCols*g.shake/shakeSecs + 0.5)
+	if int(g.shake*40)%2 == 0 {
+		return -off
+	}
+	return off
+}
+
+// shiftRow moves a row by off columns in place, right when positive,
+// padding with spaces.
+func shiftRow(buf []byte, off int) {
+	pad := off
+	if pad < 0 {
+		pad = -pad
+	}
+	if pad > len(buf) {
+		pad = len(buf)
+	}
+	gap := buf[len(buf)-pad:]
+	if off > 0 {
+		copy(buf[pad:], buf)
+		gap = buf[:pad]
+	} else {
+		copy(buf, buf[pad:])
+	}
+	for i := range gap {
+		gap[i] = ' '
+	}
+}
+
+// checkRow panics unless a rendered row is exactly width columns of plain
+// printable ASCII, the one byte per column every bit of layout relies on.
+// With halfBlock, half-block cells count as a column too.
+func checkRow(line []byte, row, width int, halfBlock bool) {
+	if len(line) != width {
+		panic(fmt.Sprintf("row %d is %d columns, want %d", row, len(line), width))
+	}
+	for x, c := range line {
+		if (c < ' ' || c > '~') && !(halfBlock && isHalfCell(c)) {
+			panic(fmt.Sprintf("row %d has byte %#x at column %d, not one printable column", row, c, x))
+		}
+	}
+}
+
+// checkFrame decodes the frame the way a terminal would and panics if what
+// comes out isn't the screen that was rendered, a cell for every column.
+// Half-block cells only have to come out as one of the half-block glyphs.
+func (g *game) checkFrame() {
+	rows, w := g.shownRows(), g.width
+	if g.clipW > 0 && g.clipW < w {
+		w = g.clipW
+	}
+	fb := g.debugFB
+	if fb == nil || fb.w != g.width || fb.h != g.top+rows {
+		// Always a fresh size, so the frame has every row
+		fb = newFramebuffer(g.width, g.top+rows)
+		g.debugFB = fb
+	}
+	fb.Write(g.frame)
+	if fb.err != nil {
+		panic(fmt.Sprintf("frame doesn't decode: %v", fb.err))
+	}
+	for row := 0; row < rows; row++ {
+		for x, c := range g.screen[row*g.width : row*g.width+w] {
+			got := fb.cell(g.top+row, x).ch
+			if isHalfCell(c) && strings.ContainsRune("▀▄█", got) || rune(c) == got {
+				continue
+			}
+			panic(fmt.Sprintf("row %d column %d decodes as %q, want %q", row, x, got, c))
+		}
+	}
+}
+
+// meterBar draws a meter n cells wide filled to level, with the last cell
+// partly filled when level falls between cells.
+func meterBar(level float64, n int) string {
+	const partial = ".:="
+	bar := make([]byte, n)
+	cells := level * float64(n)
+	for i := range bar {
+		switch fill := cells - float64(i); {
+		case fill >= 1:
+			bar[i] = '#'
+		case fill > 0:
+			bar[i] = partial[int(fill*float64(len(partial)))]
+		default:
+			bar[i] = ' '
+		}
+	}
+	return string(bar)
+}
+
+// hudSpan is the rows the HUD takes up at the top of the screen: none for a
+// bottom HUD, and below the lane debug block when they'd share a corner.
+func (g *game) hudSpan() (top, rows int) {
+	switch {
+	case g.cfg.hudPos == hudBottom:
+		return 0, 0
+	case g.cfg.hudPos == hudTopLeft && g.cfg.debugLanes:
+		return numLanes + 2, len(g.hud)
 	}
+	return 0, len(g.hud)
+}
 
-	return
+// pickHUD is the first variant that fits in width with a column to spare, so
+// narrow terminals drop the labels before they lose the number, or the last
+// variant if none do.
+func pickHUD(width int, variants ...string) string {
+	for _, v := range variants {
+		if len(v)+1 <= width {
+			return v
+		}
+	}
+	return variants[len(variants)-1]
+}
+
+// squeezeHUD puts the HUD rows on one line, stepping every entry down to its
+// next shorter variant until the line fits in width. If even the shortest
+// don't fit it gets clipped.
+func squeezeHUD(rows [][]string, width int) string {
+	longest := 0
+	for _, r := range rows {
+		longest = max(longest, len(r))
+	}
+	parts := make([]string, len(rows))
+	var line string
+	for v := 0; v < longest; v++ {
+		for i, r := range rows {
+			parts[i] = strings.TrimSpace(r[min(v, len(r)-1)])
+		}
+		line = " " + strings.Join(parts, " | ") + " "
+		if len(line) <= width {
+			break
+		}
+	}
+	return line
+}
+
+// placeHUD right-aligns the HUD variant that fits in buf. If nothing fits the
+// last variant starts at the left edge and gets clipped on the right.
+func placeHUD(buf []byte, variants ...string) {
+	hud := pickHUD(len(buf), variants...)
+
+	x := len(buf) - len(hud) - 1
+	if x < 0 {
+		x = 0
+	}
+	placeString(buf, x, hud)
 }
 
 func placeString(buf []byte, x int, s string) {
@@ -443,85 +1875,375 @@ func placeStringBytes(buf []byte, x int, s []byte) {
 }
 
 func main() {
-	fd := int(os.Stdin.Fd())
-	oldState, err := term.MakeRaw(fd)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run is the whole CLI, returning the exit code so deferred cleanup (like
+// flushing the log) always happens first.
+func run(args []string) int {
+	cfg, err := parseConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to set raw mode: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 2
+	}
+
+	if cfg.version {
+		printVersion(os.Stdout)
+		return 0
+	}
+
+	// A resumed run plays with the flags it was saved with
+	var sv *savedGame
+	if cfg.resume {
+		if sv, cfg, err = loadSave(); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't resume: %v\n", err)
+			return 1
+		}
+	}
+
+	if cfg.bench > 0 {
+		if err := runBench(cfg, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't write results: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	st, err := loadStats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't load stats, starting fresh: %v\n", err)
+	}
+
+	// Puts the terminal back once play's had it, and finishes off the log
+	// and cast if a second signal means there's no waiting for play
+	r := &restorer{}
+
+	logger := slog.New(slog.DiscardHandler)
+	if cfg.logPath != "" {
+		l, closeLog, err := openLog(cfg.logPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't open log: %v\n", err)
+			return 1
+		}
+		closeLog = r.onExit(closeLog)
+		defer func() {
+			if err := closeLog(); err != nil {
+				fmt.Fprintf(os.Stderr, "couldn't write log: %v\n", err)
+			}
+		}()
+		logger = l
+	}
+	if cfg.narrate != "" {
+		l, closeNarrator, err := openNarrator(cfg.narrate, logger, cfg.manual)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't open narration: %v\n", err)
+			return 1
+		}
+		defer closeNarrator()
+		logger = l
+	}
+
+	if cfg.headless {
+		if err := runHeadless(cfg, os.Stdout, logger); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't write results: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	if cfg.checksum {
+		if err := runChecksum(cfg, os.Stdout, logger); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't write checksums: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	// A display that can't serve metrics is still worth playing on
+	var m *metrics
+	if cfg.metrics != "" {
+		if m, err = serveMetrics(cfg.metrics); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't serve metrics, playing without them: %v\n", err)
+		} else {
+			defer m.close()
+		}
+	}
+
+	t, closeTerm, err := openTerminal(cfg.output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn't open output: %v\n", err)
+		return 1
+	}
+	defer closeTerm()
+
+	g, err := play(cfg, st, t, r, logger, sv, m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	// Out here, past the alt screen, so it stays in the scrollback to copy
+	fmt.Fprintf(os.Stderr, "seed %d, -seed %d plays this course again\n", g.seed, g.seed)
+	if cfg.share != "" {
+		if err := g.writeShareCard(cfg.share); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't write the share card: %v\n", err)
+		}
+	}
+	if g.canSave() {
+		if err := g.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't save the run: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "run saved, -resume carries on from here\n")
+		}
+	}
+
+	// The fastest ever goes by the same rules as a seed best, so a run
+	// that's had help or a head start doesn't count
+	key, ranked := seedKey(cfg, g.seed)
+	changed := ranked && st.recordTopSpeed(g.topSpeed)
+	if cfg.seeded && ranked {
+		if score, pace := g.bestRun(); st.recordSeed(key, score, pace) {
+			changed = true
+		}
+	}
+	if changed {
+		if err := st.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't save stats: %v\n", err)
+		}
+	}
+	return 0
+}
+
+// showTitle puts the title and seed at the top of the screen, centred on the
+// w columns that can be seen.
+func showTitle(t terminal, g *game, w int) {
+	title := "SUBWAY SURFER"
+	if hint := g.cfg.quitHint(); hint != "" {
+		title += " - " + hint
+	}
+	t.write(fmt.Sprintf("\033[%d;%dH%s", g.top+1, (w-len(title))/2, title))
+	sub := fmt.Sprintf("SEED %d", g.seed)
+	if g.cfg.seeded {
+		sub += " - BEST " + g.cfg.scoreText(g.seedBest, false)
+	}
+	t.write(fmt.Sprintf("\033[%d;%dH%s", g.top+2, (w-len(sub))/2, sub))
+}
+
+// play runs the game in the terminal until the player quits, and hands back
+// the finished game once r has restored the terminal. With a saved run in
+// sv it carries on from there instead of starting a new one. Every frame's
+// numbers go to m, if it's serving metrics.
+func play(cfg config, st *stats, t terminal, r *restorer, logger *slog.Logger, sv *savedGame, m *metrics) (*game, error) {
+	// Everything done to the terminal gets undone by r on the way out, or
+	// earlier by whatever gets there first. Output goes through r from here
+	// on, so nothing's written once it has
+	r.out, r.inFd = t.out, t.inFd
+	t.out = r
+	defer r.restoreTerminal()
+
+	// Demo runs never read input, so only interactive play needs a terminal
+	// on the input fd
+	interactive := !cfg.demo
+	if interactive && t.inFd >= 0 {
+		if !term.IsTerminal(t.inFd) {
+			return nil, errors.New("input isn't a terminal, interactive play needs one (try -demo or -bench)")
+		}
+		oldState, err := term.MakeRaw(t.inFd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set raw mode: %w", err)
+		}
+		r.rawMode(oldState)
 	}
-	defer term.Restore(fd, oldState)
 
 	quit := make(chan struct{})
 	var once sync.Once
 	doQuit := func() { once.Do(func() { close(quit) }) }
 
+	// SIGINT stays trapped even with -ctrl-c=false, so it can't kill the
+	// game and leave the terminal in raw mode. A second signal means the
+	// loop's stuck and never got round to the first, so that one puts the
+	// terminal back, finishes off the log and cast, and goes
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	go func() { <-sigs; doQuit() }()
+	defer signal.Stop(sigs)
 	go func() {
-		b := make([]byte, 1)
-		for {
-			n, err := os.Stdin.Read(b)
-			if err != nil || n == 0 {
-				return
+		defer r.restoreOnPanic()
+		quitting := false
+		for sig := range sigs {
+			if sig == syscall.SIGINT && !cfg.ctrlC {
+				continue
 			}
-			if b[0] == 'q' || b[0] == 3 {
-				doQuit()
-				return
+			if quitting {
+				r.shutDown()
+				os.Exit(1)
 			}
+			quitting = true
+			doQuit()
 		}
 	}()
+	keys := make(chan keyPress, 8)
+	if interactive {
+		go func() {
+			defer r.restoreOnPanic()
+			readKeys(t.in, func(k keyPress) bool {
+				if cfg.quits(k) {
+					doQuit()
+					return false
+				}
+				select {
+				case keys <- k:
+				default:
+				}
+				return true
+			})
+		}()
+	}
 
-	w, h, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		w, h = 80, 24
+	// With -geometry the terminal is taken to be that size, and never asked
+	w, h := cfg.width, cfg.height
+	if !cfg.geometry {
+		w, h, _ = t.size()
+	}
+	realW, realH := w, h
+	if cfg.width > 0 {
+		w, h = cfg.width, cfg.height
+	} else {
+		w, h = cfg.playSize(w, h)
 	}
 
-	g := newGame(w, h)
+	g := newGame(w, h, cfg)
+	g.log = logger
+	if sv != nil {
+		sv.restore(g)
+		g.log.Info("resume", "t", g.elapsed, "seed", g.seed, "score", g.score)
+	}
+	m.newRun()
 	g.frame = make([]byte, 0, w*h*2)
+	g.bestSpeed = st.TopSpeed
+	if cfg.seeded {
+		key, _ := seedKey(cfg, g.seed)
+		g.seedBest, g.bestPace = st.seedBest(key), st.seedPace(key)
+	}
+	if w > realW || h > realH {
+		g.clipW, g.clipH = realW, realH
+		if cfg.width > 0 {
+			fmt.Fprintf(os.Stderr, "terminal is %dx%d, smaller than -size %dx%d, the edges will be cut off\n", realW, realH, w, h)
+		}
+		g.log.Info("clip", "width", realW, "height", realH)
+	}
 
-	// Setup screen
-	os.Stdout.WriteString("\033[?1049h") // alt screen
-	os.Stdout.WriteString("\033[?25l")   // hide cursor
-	os.Stdout.WriteString("\033[2J")     // clear
-	defer func() {
-		os.Stdout.WriteString("\033[?25h")   // show cursor
-		os.Stdout.WriteString("\033[?1049l") // restore screen
-	}()
+	// Opened before the screen is set up so the cast catches the setup and,
+	// closing after it, the teardown too
+	if cfg.cast != "" {
+		castW, castH := w, h
+		if g.clipW > 0 {
+			castW, castH = g.clipW, g.clipH
+		}
+		c, err := openCast(cfg.cast, r.out, castW, castH)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open cast: %w", err)
+		}
+		closeCast := r.onExit(c.Close)
+		defer func() {
+			r.restoreTerminal()
+			if err := closeCast(); err != nil {
+				fmt.Fprintf(os.Stderr, "couldn't write cast: %v\n", err)
+			}
+		}()
+		r.writeThrough(c)
+	}
+
+	// Setup screen, which r takes down again. Inline, with -no-altscreen,
+	// the game goes at the bottom of the screen: newlines push what was
+	// there up into the scrollback to make room, and the last frame's left
+	// where it is with the cursor below it
+	if cfg.noAltScreen {
+		g.top = max(realH-g.shownRows(), 0)
+		t.write(strings.Repeat("\n", g.shownRows()))
+	} else {
+		t.write("\033[?1049h") // alt screen
+	}
+	t.write("\033[?25l") // hide cursor
+	t.write("\033[?7l")  // no autowrap, a row that's too long can't spill onto the next
+	t.write(g.clearScreen())
+	r.screenUp(func() string {
+		if cfg.noAltScreen {
+			return fmt.Sprintf("\033[%d;1H\r\n", g.top+g.shownRows())
+		}
+		return "\033[?1049l" // restore screen
+	})
 
-	// Title
-	title := "SUBWAY SURFER - press q to quit"
-	os.Stdout.WriteString(fmt.Sprintf("\033[1;%dH%s", (w-len(title))/2, title))
-	time.Sleep(time.Second)
+	// Title for a second, then the countdown, unless we're in a hurry
+	if cfg.skipIntro {
+		g.countdown = 0
+	} else {
+		showTitle(t, g, min(w, realW))
+		time.Sleep(time.Second)
+	}
 
 	ticker := time.NewTicker(time.Second / targetFPS)
 	defer ticker.Stop()
 	last := time.Now()
+	sizes := newSizeWatch(realW, realH)
+	sizes.off = cfg.width != 0 // the size is forced
+	overFor := 0.0             // how long the game over panel's been up, for -challenge
 
 	for {
 		select {
 		case <-quit:
-			return
+			return g, nil
+		case k := <-keys:
+			g.key(k)
 		case <-ticker.C:
+			// A stall (a suspended process, a slow terminal, a laptop lid)
+			// would otherwise come back as one huge step. Hits are swept
+			// along the whole step so nothing passes through the runner,
+			// but the autopilot, keys and leniency window only get a look in
+			// between steps: a long one carries trains from out of sight to
+			// past the runner with no chance to dodge. So a step never goes
+			// past -max-step and the rest of a stall is just lost, which
+			// also means a terminal that can't keep up plays in slow motion
 			now := time.Now()
-			dt := now.Sub(last).Seconds()
-			if dt > 0.1 {
-				dt = 0.1
-			}
+			dt := cfg.stepFor(now.Sub(last))
 			last = now
 
 			// Check resize
-			if nw, nh, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
-				if nw != g.width || nh != g.height {
-					g.width = nw
-					g.height = nh
-					os.Stdout.WriteString("\033[2J")
+			if resized, err := sizes.check(t.size); err != nil {
+				g.log.Info("resize.off", "t", g.elapsed, "width", g.width, "height", g.height, "err", err)
+			} else if resized {
+				nw, nh := sizes.w, sizes.h
+				g.log.Info("resize", "t", g.elapsed, "width", nw, "height", nh)
+				g.width, g.height = cfg.playSize(nw, nh)
+				g.clipW, g.clipH = 0, 0
+				if g.width > nw || g.height > nh {
+					g.clipW, g.clipH = nw, nh
+					g.log.Info("clip", "width", nw, "height", nh)
 				}
+				if cfg.noAltScreen {
+					g.top = max(nh-g.shownRows(), 0)
+				}
+				g.redraw = true
+				t.write(g.clearScreen())
 			}
 
 			g.update(dt)
+
+			// A challenge run starts over a moment after it's lost
+			if cfg.challenge && g.over {
+				overFor += dt
+				if overFor >= retrySecs {
+					overFor = 0
+					g.restart()
+					m.newRun()
+				}
+			}
+
 			frame := g.render()
-			os.Stdout.Write(frame)
+			t.out.Write(frame)
+			m.publish(g, now)
 		}
 	}
 }
@@ -0,0 +1,992 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConfig parses args the way the command line would, failing the test
+// on a bad flag.
+func testConfig(t testing.TB, args ...string) config {
+	t.Helper()
+	cfg, err := parseConfig(args)
+	if err != nil {
+		t.Fatalf("parseConfig(%q): %v", args, err)
+	}
+	return cfg
+}
+
+// testGame is a seeded w by h game, past the countdown and with the controls
+// bar off, ready to step.
+func testGame(t testing.TB, w, h int, args ...string) *game {
+	t.Helper()
+	g := newGame(w, h, testConfig(t, append([]string{"-seed", "1", "-hints=false"}, args...)...))
+	g.countdown = 0
+	return g
+}
+
+// screenRows renders a whole frame and returns it a row at a time.
+func screenRows(g *game) []string {
+	g.redraw = true
+	g.render()
+	rows := make([]string, 0, g.height)
+	for r := 0; g.width > 0 && (r+1)*g.width <= len(g.screen); r++ {
+		rows = append(rows, string(g.screen[r*g.width:(r+1)*g.width]))
+	}
+	return rows
+}
+
+// clearTrack takes every obstacle and coin off the track.
+func clearTrack(g *game) {
+	for i := range g.obstacles {
+		g.obstacles[i].active = false
+	}
+	for i := range g.coinPool {
+		g.coinPool[i].active = false
+	}
+}
+
+func TestPlaceHUD(t *testing.T) {
+	variants := []string{" SCORE: 0001234 ", " S:1234 ", "1234"}
+	tests := []struct {
+		width int
+		want  string // the row, right-aligned a column in from the edge
+	}{
+		{80, strings.Repeat(" ", 63) + " SCORE: 0001234  "},
+		{17, " SCORE: 0001234  "},
+		{16, strings.Repeat(" ", 7) + " S:1234  "},
+		{9, " S:1234  "},
+		{8, "   1234 "},
+		{5, "1234 "},
+		{3, "123"}, // nothing fits, so the number's cut off
+	}
+	for _, tt := range tests {
+		buf := []byte(strings.Repeat(" ", tt.width))
+		placeHUD(buf, variants...)
+		if got := string(buf); got != tt.want {
+			t.Errorf("placeHUD at %d columns = %q, want %q", tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestHUDNarrowWidths(t *testing.T) {
+	for _, w := range []int{8, 10, 12, 16, 20, 30, 40, 80} {
+		g := testGame(t, w, 24)
+		g.score, g.coins = 1234, 56
+		rows := screenRows(g)
+		for i, row := range rows {
+			if len(row) != w {
+				t.Fatalf("width %d: row %d is %d columns", w, i, len(row))
+			}
+		}
+		if !strings.Contains(rows[0], "1234") {
+			t.Errorf("width %d: score missing from the HUD: %q", w, rows[0])
+		}
+		if !strings.Contains(rows[1], "56") {
+			t.Errorf("width %d: coins missing from the HUD: %q", w, rows[1])
+		}
+	}
+}
+
+// logEvents sends the game's log to the returned buffer as text, without
+// timestamps or the attrs named in drop.
+func logEvents(g *game, drop ...string) *bytes.Buffer {
+	var buf bytes.Buffer
+	g.log = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == slog.LevelKey || slices.Contains(drop, a.Key) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	return &buf
+}
+
+// eventLines is the lines in a log from logEvents for the events named.
+func eventLines(buf *bytes.Buffer, events ...string) []string {
+	var lines []string
+	for _, l := range strings.Split(buf.String(), "\n") {
+		for _, e := range events {
+			if strings.HasPrefix(l, "msg="+e+" ") {
+				lines = append(lines, l)
+			}
+		}
+	}
+	return lines
+}
+
+// lcgSource is a simple, fixed random source for tests.
+type lcgSource struct{ n uint64 }
+
+func (s *lcgSource) Int63() int64 {
+	s.n = s.n*6364136223846793005 + 1442695040888963407
+	return int64(s.n >> 1)
+}
+
+func (s *lcgSource) Seed(seed int64) { s.n = uint64(seed) }
+
+// sourceGame is a game on src, past the countdown.
+func sourceGame(t *testing.T, src rand.Source) *game {
+	g := newGameWithSource(80, 24, testConfig(t, "-hints=false"), src)
+	g.countdown = 0
+	return g
+}
+
+// spawns plays secs of g and returns what it spawned.
+func spawns(g *game, secs float64) []string {
+	buf := logEvents(g, "t")
+	for range int(secs / 0.05) {
+		g.update(0.05)
+	}
+	return eventLines(buf, "spawn.obstacle", "spawn.coins", "spawn.pattern")
+}
+
+func TestSourceSpawnSequence(t *testing.T) {
+	got := spawns(sourceGame(t, &lcgSource{n: 42}), 3)
+	want := []string{
+		"msg=spawn.coins lane=2 step=1 gold=false z=19",
+		"msg=spawn.obstacle lane=1 kind=1 z=19",
+		"msg=spawn.coins lane=1 step=0 gold=false z=19",
+		"msg=spawn.coins lane=2 step=0 gold=false z=19",
+		"msg=spawn.obstacle lane=2 kind=0 z=19",
+		"msg=spawn.coins lane=0 step=0 gold=false z=19",
+		"msg=spawn.coins lane=0 step=0 gold=false z=19",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("spawns from a fixed source:\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The source is the only randomness: the same one again spawns the
+	// same, and a different one doesn't
+	if again := spawns(sourceGame(t, &lcgSource{n: 42}), 3); !slices.Equal(again, got) {
+		t.Errorf("the same source spawned differently the second time:\n%s", strings.Join(again, "\n"))
+	}
+	if other := spawns(sourceGame(t, &lcgSource{n: 7}), 3); slices.Equal(other, got) {
+		t.Error("a different source spawned exactly the same")
+	}
+}
+
+func TestSourceMatchesSeed(t *testing.T) {
+	// A seeded game is the same game as one handed that seed's source
+	seeded := testGame(t, 80, 24, "-seed", "5")
+	want := spawns(seeded, 10)
+	if got := spawns(sourceGame(t, rand.NewSource(5)), 10); !slices.Equal(got, want) {
+		t.Errorf("seed 5's source spawned\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(want) < 10 {
+		t.Errorf("only %d spawns in 10 seconds", len(want))
+	}
+}
+
+func TestPauseFreezesTimers(t *testing.T) {
+	g := testGame(t, 80, 24)
+	ref := testGame(t, 80, 24)
+	for range 40 {
+		g.update(0.05)
+		ref.update(0.05)
+	}
+
+	g.paused = true
+	before := *g
+	for range 200 {
+		g.update(0.05)
+	}
+	if after := *g; !reflect.DeepEqual(after, before) {
+		t.Errorf("state moved while paused:\n%+v\nwant\n%+v", after, before)
+	}
+
+	// Picking up again carries on as if the pause never happened
+	g.paused = false
+	for range 40 {
+		g.update(0.05)
+		ref.update(0.05)
+	}
+	if !reflect.DeepEqual(*g, *ref) {
+		t.Errorf("after a pause the run went\n%+v\nwant\n%+v", *g, *ref)
+	}
+}
+
+func TestCoinReach(t *testing.T) {
+	tests := []struct {
+		window string
+		lanes  string
+		lane   int // runner's in lane 1
+		z, dt  float64
+		want   bool
+	}{
+		{"1.5", "0", 1, 1.0, 0.01, true},
+		{"1.5", "0", 1, 2.0, 0.01, false},
+		{"3", "0", 1, 2.0, 0.01, true},
+		{"3", "0", 1, 3.5, 0.01, false},
+		{"3", "0", 0, 1.0, 0.01, false},
+		{"3", "1", 0, 1.0, 0.01, true},
+		{"3", "1", 2, 1.0, 0.01, true},
+		{"1.5", "0", 1, -0.5, 0.01, false},
+		// Just past the runner, but it was in the window earlier in the tick
+		{"1.5", "0", 1, -0.05, 0.05, true},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-coin-window", tt.window, "-coin-lanes", tt.lanes)
+		c := coinObj{lane: tt.lane, x: float64(tt.lane), z: tt.z, active: true}
+		if got := g.inCoinReach(c, tt.dt); got != tt.want {
+			t.Errorf("window %s, lanes %s: coin in lane %d at z %v in reach = %v, want %v", tt.window, tt.lanes, tt.lane, tt.z, got, tt.want)
+		}
+	}
+}
+
+func TestCoinWindowGrabs(t *testing.T) {
+	for _, tt := range []struct {
+		window, value string
+		grabbed       bool
+	}{
+		{"1.5", "50", false},
+		{"3", "50", true},
+		{"3", "20", true},
+	} {
+		g := testGame(t, 80, 24, "-mode", "practice", "-coin-window", tt.window, "-coin-value", tt.value, "-distance-value", "0")
+		clearTrack(g)
+		g.coinPool[0] = coinObj{lane: 1, x: 1, z: 2.5, active: true}
+		g.update(0.01)
+		if got := g.coins == 1; got != tt.grabbed {
+			t.Errorf("window %s: coin at z 2.5 grabbed = %v, want %v", tt.window, got, tt.grabbed)
+		}
+		if want := map[bool]string{true: tt.value, false: "0"}[tt.grabbed]; strconv.Itoa(g.score) != want {
+			t.Errorf("window %s, value %s: scored %d, want %s", tt.window, tt.value, g.score, want)
+		}
+	}
+}
+
+func TestRenderTinySizes(t *testing.T) {
+	for _, view := range [][]string{{}, {"-view", "topdown"}, {"-halfblock"}} {
+		for _, w := range []int{0, 1, 2, 5, 80} {
+			for _, h := range []int{0, 1, 2, 3} {
+				g := testGame(t, w, h, append([]string{"-debug-render"}, view...)...)
+				for range 20 {
+					g.update(0.05)
+				}
+				rows := screenRows(g)
+				if w > 0 && h > 0 && len(rows) != h {
+					t.Errorf("%v %dx%d: rendered %d rows", view, w, h, len(rows))
+				}
+				for i, row := range rows {
+					if len(row) != w {
+						t.Errorf("%v %dx%d: row %d is %d columns", view, w, h, i, len(row))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestObjectCapUnderStress(t *testing.T) {
+	for _, limit := range []string{"5", "8", "15"} {
+		// Slow enough that everything lingers on the track
+		cfg := testConfig(t, "-seed", "9", "-mode", "practice", "-max-objects", limit, "-start-speed", "2", "-speed-ramp", "0")
+		g := newGame(80, 24, cfg)
+		g.countdown = 0
+		buf := logEvents(g)
+		most := 0
+		for range 60 * 20 {
+			g.update(0.05)
+			obstacles, coins := g.activeObjects()
+			if obstacles+coins > cfg.maxObjects {
+				t.Fatalf("cap %d: %d obstacles and %d coins on the track", cfg.maxObjects, obstacles, coins)
+			}
+			most = max(most, obstacles+coins)
+		}
+		// Coins back off first, so obstacles keep coming even at the cap
+		if n := len(eventLines(buf, "spawn.obstacle")); n < 20 {
+			t.Errorf("cap %d: only %d obstacles spawned", cfg.maxObjects, n)
+		}
+		if most < cfg.maxObjects-coinsPerLine {
+			t.Errorf("cap %d: the track never got near full, at most %d", cfg.maxObjects, most)
+		}
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	for _, view := range []struct {
+		name string
+		args []string
+	}{
+		{"perspective", nil},
+		{"topdown", []string{"-view", "topdown"}},
+		{"halfblock", []string{"-halfblock"}},
+	} {
+		b.Run(view.name, func(b *testing.B) {
+			// A few seconds in, so there's plenty on the track
+			g := testGame(b, 120, 40, append([]string{"-seed", "3", "-mode", "practice"}, view.args...)...)
+			for range 5 * targetFPS {
+				g.update(1.0 / targetFPS)
+			}
+			g.render()
+			b.ReportAllocs()
+			sent := 0
+			for b.Loop() {
+				g.redraw = true
+				sent += len(g.render())
+			}
+			// What a full redraw costs the terminal, not just the CPU
+			b.ReportMetric(float64(sent)/float64(b.N), "bytes/frame")
+		})
+	}
+}
+
+func TestFairStart(t *testing.T) {
+	early := 0
+	for seed := range 300 {
+		g := newGame(80, 24, testConfig(t, "-seed", strconv.Itoa(seed)))
+		for _, o := range g.obstacles {
+			if o.active && o.lane == g.runnerLane {
+				t.Fatalf("seed %d: the countdown's obstacle is in the runner's lane", seed)
+			}
+		}
+		early++
+
+		g.countdown = 0
+		buf := logEvents(g)
+		for g.elapsed < fairStartSecs {
+			g.spawnTimer = 100 // an obstacle every tick
+			g.update(0.05)
+		}
+		for _, l := range eventLines(buf, "spawn.obstacle") {
+			var at float64
+			var lane int
+			fmt.Sscanf(l, "msg=spawn.obstacle t=%g lane=%d", &at, &lane)
+			if at >= fairStartSecs {
+				continue
+			}
+			early++
+			if lane == 1 {
+				t.Errorf("seed %d: obstacle spawned in the runner's lane at %vs", seed, at)
+			}
+		}
+	}
+	if early < 1000 {
+		t.Errorf("only %d early spawns checked", early)
+	}
+}
+
+func TestCoinLanes(t *testing.T) {
+	tests := []struct {
+		lane, step int
+		want       [coinsPerLine]int
+	}{
+		{0, 0, [coinsPerLine]int{0, 0, 0}},
+		{2, 0, [coinsPerLine]int{2, 2, 2}},
+		{0, 1, [coinsPerLine]int{0, 1, 2}},
+		{2, -1, [coinsPerLine]int{2, 1, 0}},
+		// Diagonals from the middle bounce off the edge
+		{1, 1, [coinsPerLine]int{1, 2, 1}},
+		{1, -1, [coinsPerLine]int{1, 0, 1}},
+	}
+	for _, tt := range tests {
+		if got := coinLanes(tt.lane, tt.step); got != tt.want {
+			t.Errorf("coinLanes(%d, %d) = %v, want %v", tt.lane, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestSpawnCoinLaysDiagonals(t *testing.T) {
+	diagonals := 0
+	for seed := range 50 {
+		g := testGame(t, 80, 24, "-seed", strconv.Itoa(seed))
+		clearTrack(g)
+		buf := logEvents(g, "t")
+		g.spawnCoin()
+		var lane, step int
+		fmt.Sscanf(eventLines(buf, "spawn.coins")[0], "msg=spawn.coins lane=%d step=%d", &lane, &step)
+		if step != 0 {
+			diagonals++
+		}
+		want := coinLanes(lane, step)
+		for j, c := range g.coinPool[:coinsPerLine] {
+			if !c.active || c.lane != want[j] {
+				t.Errorf("seed %d: coin %d is in lane %d (active %v), want %d", seed, j, c.lane, c.active, want[j])
+			}
+		}
+	}
+	if diagonals == 0 {
+		t.Error("no diagonal coin lines in 50 seeds")
+	}
+}
+
+func TestCoinSpacing(t *testing.T) {
+	for _, spacing := range []string{"1", "1.5", "2.5", "6"} {
+		g := testGame(t, 80, 24, "-coin-spacing", spacing)
+		space, _ := strconv.ParseFloat(spacing, 64)
+		lines := map[string]func(){
+			"spawned":  g.spawnCoin,
+			"scripted": func() { g.scriptCoins(courseEvent{lane: 1, step: 1}, 10) },
+		}
+		starts := map[string]float64{"spawned": g.cfg.spawnZ, "scripted": 10}
+		for name, lay := range lines {
+			clearTrack(g)
+			lay()
+			for j, c := range g.coinPool[:coinsPerLine] {
+				if want := starts[name] + float64(j)*space; !c.active || c.z != want {
+					t.Errorf("-coin-spacing %s, %s: coin %d at z %v (active %v), want %v", spacing, name, j, c.z, c.active, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSpacingFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-coin-spacing", "0.9"},
+		{"-coin-spacing", "6.5"},
+		{"-pattern-spacing", "0.4"},
+		{"-pattern-spacing", "3.5"},
+		// Lands from jump-then-slide's wall after its bar's gone by
+		{"-pattern-spacing", "0.8", "-jump-secs", "1.2"},
+	} {
+		if _, err := parseConfig(args); err == nil {
+			t.Errorf("%q: took it", args)
+		}
+	}
+}
+
+func TestSeedSpawnLog(t *testing.T) {
+	g := testGame(t, 80, 24, "-seed", "3", "-mode", "practice", "-closure-every", "4")
+	buf := logEvents(g, "t", "z")
+	for range 8 * 20 {
+		g.update(0.05)
+	}
+	got := eventLines(buf, "spawn.obstacle", "spawn.coins", "spawn.pattern", "closure")
+	want := []string{
+		"msg=spawn.coins lane=0 step=0 gold=false",
+		"msg=spawn.obstacle lane=2 kind=2",
+		"msg=spawn.coins lane=0 step=0 gold=false",
+		"msg=spawn.coins lane=0 step=0 gold=false",
+		"msg=spawn.obstacle lane=2 kind=1",
+		"msg=spawn.coins lane=1 step=0 gold=false",
+		"msg=spawn.coins lane=2 step=0 gold=false",
+		"msg=spawn.obstacle lane=0 kind=0",
+		"msg=spawn.coins lane=2 step=0 gold=false",
+		"msg=closure lane=0 length=30",
+		"msg=spawn.coins lane=1 step=1 gold=false",
+		"msg=spawn.obstacle lane=1 kind=0",
+		"msg=spawn.coins lane=2 step=-1 gold=false",
+		"msg=spawn.coins lane=0 step=1 gold=false",
+		"msg=spawn.coins lane=0 step=-1 gold=false",
+		"msg=spawn.obstacle lane=2 kind=1",
+		"msg=spawn.coins lane=1 step=0 gold=false",
+		"msg=spawn.coins lane=0 step=0 gold=false",
+		"msg=spawn.coins lane=2 step=0 gold=false",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("seed 3 spawned\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// And the rest of a minute draws just as many times from the RNG
+	for range 52 * 20 {
+		g.update(0.05)
+	}
+	if g.src.draws != 446 {
+		t.Errorf("seed 3 made %d random draws in a minute, want 446", g.src.draws)
+	}
+}
+
+func TestSpawnOrderSameTick(t *testing.T) {
+	// Both timers go off on the same tick: the obstacle always draws first
+	g := testGame(t, 80, 24, "-seed", "3", "-mode", "practice", "-closure-every", "4")
+	clearTrack(g)
+	g.elapsed = 5 // past the fair start
+	g.spawnTimer, g.coinTimer = 100, 0.6
+	buf := logEvents(g, "t", "z")
+	g.update(0.001)
+	got := eventLines(buf, "spawn.obstacle", "spawn.coins")
+	if len(got) != 2 || !strings.HasPrefix(got[0], "msg=spawn.obstacle") || !strings.HasPrefix(got[1], "msg=spawn.coins") {
+		t.Errorf("same tick spawns went %q, want an obstacle then coins", got)
+	}
+}
+
+func TestCoinMagnet(t *testing.T) {
+	tests := []struct {
+		reach      string
+		runner     int
+		lane       int
+		collected  bool
+		pulledOver bool // drawn partway over to the runner on the way in
+	}{
+		{"0", 1, 1, true, false},
+		{"0", 1, 0, false, false},
+		{"1", 1, 0, true, true},
+		{"1", 1, 2, true, true},
+		{"1", 0, 2, false, false},
+		{"2", 0, 2, true, true},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-mode", "practice", "-coin-lanes", tt.reach, "-manual")
+		clearTrack(g)
+		g.runnerLane, g.targetLane, g.laneX = tt.runner, tt.runner, float64(tt.runner)
+		g.coinPool[0] = coinObj{lane: tt.lane, x: float64(tt.lane), z: 10, active: true}
+		pulled := false
+		for range 100 {
+			g.update(0.02)
+			c := g.coinPool[0]
+			if c.active && c.x != float64(c.lane) {
+				pulled = true
+				if lo, hi := min(c.lane, tt.runner), max(c.lane, tt.runner); c.x < float64(lo) || c.x > float64(hi) {
+					t.Errorf("reach %s: coin drawn at %v, outside lanes %d to %d", tt.reach, c.x, lo, hi)
+				}
+			}
+		}
+		if got := g.coins == 1; got != tt.collected {
+			t.Errorf("reach %s, runner in %d: coin in lane %d collected = %v, want %v", tt.reach, tt.runner, tt.lane, got, tt.collected)
+		}
+		if pulled != tt.pulledOver {
+			t.Errorf("reach %s, runner in %d: coin in lane %d pulled over = %v, want %v", tt.reach, tt.runner, tt.lane, pulled, tt.pulledOver)
+		}
+	}
+}
+
+func TestCoinGrabPriority(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		coins  []coinObj
+		dt     float64
+		grabs  int  // coins taken in the one tick
+		golden bool // whether the gold one was among them
+	}{
+		{
+			"gold over normal under the magnet",
+			[]string{"-coin-lanes", "1"},
+			[]coinObj{{lane: 0, z: 1}, {lane: 2, z: 1}, {lane: 1, z: 1.2, gold: true}},
+			0.01, 2, true,
+		},
+		{
+			"gold in a side lane first",
+			[]string{"-coin-lanes", "1"},
+			[]coinObj{{lane: 1, z: 1}, {lane: 1, z: 1.1}, {lane: 0, z: 1.2, gold: true}},
+			0.01, 2, true,
+		},
+		{
+			"no magnet, no gold out of the lane",
+			[]string{"-coin-lanes", "0"},
+			[]coinObj{{lane: 1, z: 1}, {lane: 0, z: 1, gold: true}},
+			0.01, 1, false,
+		},
+		{
+			// A tick that runs past a whole line at speed takes all of it
+			"whole line in one fast tick",
+			[]string{"-coin-lanes", "0", "-start-speed", "40", "-max-speed", "0", "-coin-window", "3"},
+			[]coinObj{{lane: 1, z: 0.5}, {lane: 1, z: 2}, {lane: 1, z: 2.9, gold: true}},
+			0.1, 3, true,
+		},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, append([]string{"-mode", "practice", "-manual", "-distance-value", "0"}, tt.args...)...)
+		clearTrack(g)
+		for i, c := range tt.coins {
+			c.x, c.active = float64(c.lane), true
+			g.coinPool[i] = c
+		}
+		buf := logEvents(g)
+		g.update(tt.dt)
+		grabbed := eventLines(buf, "coin")
+		if len(grabbed) != tt.grabs {
+			t.Errorf("%s: grabbed %d coins, want %d: %v", tt.name, len(grabbed), tt.grabs, grabbed)
+		}
+		if golden := slices.ContainsFunc(grabbed, func(l string) bool { return strings.Contains(l, "gold=true") }); golden != tt.golden {
+			t.Errorf("%s: gold grabbed = %v, want %v", tt.name, golden, tt.golden)
+		}
+	}
+}
+
+func TestChallengeRestart(t *testing.T) {
+	g := testGame(t, 80, 24, "-seed", "5", "-challenge", "-mode", "practice")
+	fresh := testGame(t, 80, 24, "-seed", "5", "-challenge", "-mode", "practice")
+	want := spawns(fresh, 10)
+	for range 200 {
+		g.update(0.05)
+	}
+	first := g.score
+	g.restart()
+	if g.attempt != 2 || g.bestScore != first || g.score != 0 {
+		t.Errorf("after one try: attempt %d, best %d, score %d, want attempt 2, best %d", g.attempt, g.bestScore, g.score, first)
+	}
+	if g.countdown != 0 || g.elapsed != 0 {
+		t.Errorf("restarted with a %vs countdown %vs in", g.countdown, g.elapsed)
+	}
+
+	// The same course again, from the top
+	if got := spawns(g, 10); !slices.Equal(got, want) {
+		t.Errorf("second try spawned\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for range 200 {
+		g.update(0.05)
+	}
+	g.score = 0
+	g.restart()
+	if g.attempt != 3 || g.bestScore != first {
+		t.Errorf("after a worse try: attempt %d, best %d, want attempt 3, best %d", g.attempt, g.bestScore, first)
+	}
+}
+
+func TestLaneMarker(t *testing.T) {
+	tests := []struct {
+		target int
+		laneX  float64
+		want   string
+	}{
+		{0, 0, " [ ^ ]   |   | "},
+		{1, 1, " |   [ ^ ]   | "},
+		{2, 2, " |   |   [ ^ ] "},
+		{2, 1.75, " |   |   [^  ] "}, // on its way over
+	}
+	g := testGame(t, 80, 24, "-lane-marker")
+	for _, tt := range tests {
+		g.targetLane, g.laneX = tt.target, tt.laneX
+		if got := g.laneMarkerLine(); got != tt.want {
+			t.Errorf("heading for lane %d at %v: got %q, want %q", tt.target, tt.laneX, got, tt.want)
+		}
+	}
+
+	// It takes the bottom row and pushes the controls up one
+	g = testGame(t, 80, 24, "-lane-marker", "-hints")
+	rows := screenRows(g)
+	if !strings.Contains(rows[23], "[ ^ ]") {
+		t.Errorf("no lane gauge on the bottom row: %q", rows[23])
+	}
+	if hint := g.cfg.controlsHint(); !strings.Contains(rows[22], hint) {
+		t.Errorf("controls not just above the gauge: %q", rows[22])
+	}
+}
+
+func TestPlaySize(t *testing.T) {
+	tests := []struct {
+		mode, min    string
+		w, h         int
+		playW, playH int
+		tooSmall     bool
+	}{
+		{"wait", "40x10", 80, 24, 80, 24, false},
+		{"wait", "40x10", 30, 24, 30, 24, true},
+		{"wait", "40x10", 80, 6, 80, 6, true},
+		{"wait", "40x10", 40, 10, 40, 10, false},
+		{"clamp", "40x10", 80, 24, 80, 24, false},
+		{"clamp", "40x10", 30, 24, 40, 24, false},
+		{"clamp", "40x10", 30, 6, 40, 10, false},
+		{"clamp", "100x30", 80, 24, 100, 30, false},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, "-too-small", tt.mode, "-min-size", tt.min)
+		w, h := cfg.playSize(tt.w, tt.h)
+		if w != tt.playW || h != tt.playH {
+			t.Errorf("%s at %s: %dx%d plays at %dx%d, want %dx%d", tt.mode, tt.min, tt.w, tt.h, w, h, tt.playW, tt.playH)
+		}
+		g := newGame(w, h, cfg)
+		if g.tooSmall() != tt.tooSmall {
+			t.Errorf("%s at %s: %dx%d too small = %v, want %v", tt.mode, tt.min, tt.w, tt.h, g.tooSmall(), tt.tooSmall)
+		}
+	}
+}
+
+func TestTooSmallWaits(t *testing.T) {
+	g := testGame(t, 80, 24, "-min-size", "40x10")
+	for range 20 {
+		g.update(0.05)
+	}
+	before := g.saved()
+
+	// Shrunk below -min-size, nothing moves and the screen says why
+	g.width, g.height = 30, 8
+	for range 20 {
+		g.update(0.05)
+	}
+	if !reflect.DeepEqual(g.saved(), before) {
+		t.Error("the run went on while the terminal was too small")
+	}
+	if rows := screenRows(g); !strings.Contains(rows[g.height/2], "resize to continue") {
+		t.Errorf("no resize message, middle row %q", rows[g.height/2])
+	}
+
+	// Back up to size, it carries on from where it was
+	g.width, g.height = 80, 24
+	g.update(0.05)
+	if g.elapsed <= before.Elapsed {
+		t.Error("the run didn't pick up again once resized")
+	}
+}
+
+func TestTooSmallClampPlaysOn(t *testing.T) {
+	cfg := testConfig(t, "-seed", "1", "-too-small", "clamp", "-min-size", "40x10")
+	w, h := cfg.playSize(30, 6)
+	g := newGame(w, h, cfg)
+	g.countdown = 0
+	for range 20 {
+		g.update(0.05)
+	}
+	if g.elapsed == 0 {
+		t.Error("a clamped run waited")
+	}
+	if rows := screenRows(g); len(rows) != 10 || len(rows[0]) != 40 {
+		t.Errorf("clamped run drew %d rows of %d, want 10 of 40", len(rows), len(rows[0]))
+	}
+}
+
+func TestMinSizeFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-too-small", "shrink"},
+		{"-min-size", "40x10", "-size", "30x10"},
+	} {
+		if _, err := parseConfig(args); err == nil {
+			t.Errorf("%q: took it", args)
+		}
+	}
+}
+
+func TestLongStall(t *testing.T) {
+	// A train three seconds off in the runner's lane, then the process is
+	// stopped for a minute. The step that comes back is capped, so the
+	// train's still ahead afterwards and the autopilot has a chance at it
+	tests := []struct {
+		maxStep string
+		stall   time.Duration
+	}{
+		{"0.1", time.Minute},
+		{"0.5", time.Minute},
+		{"0.1", 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-mode", "practice", "-max-step", tt.maxStep)
+		clearTrack(g)
+		buf := logEvents(g)
+		g.obstacles[0] = obstacle{lane: g.runnerLane, kind: kindBarrier, z: runnerZ + 3*g.speed, active: true}
+
+		dt := g.cfg.stepFor(tt.stall)
+		if want := min(tt.stall.Seconds(), g.cfg.maxStep); dt != want {
+			t.Errorf("max step %s: %v stall stepped %v, want %v", tt.maxStep, tt.stall, dt, want)
+		}
+		g.update(dt)
+		if o := g.obstacles[0]; !o.active || o.z <= runnerZ {
+			t.Errorf("max step %s: after a %v stall the train's at z %v, past the runner", tt.maxStep, tt.stall, o.z)
+		}
+		for range 100 {
+			g.update(1.0 / targetFPS)
+		}
+		if crashes := eventLines(buf, "crash"); len(crashes) > 0 {
+			t.Errorf("max step %s: crashed after a %v stall: %v", tt.maxStep, tt.stall, crashes)
+		}
+	}
+}
+
+func TestMaxStepFlag(t *testing.T) {
+	for _, step := range []string{"0", "0.01", "0.6", "-1"} {
+		if _, err := parseConfig([]string{"-max-step", step}); err == nil {
+			t.Errorf("took -max-step %s", step)
+		}
+	}
+}
+
+func TestCoinSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		z    float64
+		gold bool
+		want string // what the coin adds to the frame, glyphs as o
+	}{
+		{"far off", 16, false, "."},
+		{"far off gold", 16, true, "@"},
+		{"middle distance", 10, false, "o"},
+		{"up close", 4, false, "(o)"},
+		{"up close gold", 4, true, "(@)"},
+	}
+	for _, tt := range tests {
+		// Past the GO banner, which covers the far track
+		g := testGame(t, 120, 40, "-ground-every", "0")
+		for range 40 {
+			g.update(0.05)
+		}
+		clearTrack(g)
+		before := screenRows(g)
+		g.coinPool[0] = coinObj{lane: 0, x: 0, z: tt.z, gold: tt.gold, active: true}
+		var got []byte
+		for r, row := range screenRows(g) {
+			for x := range len(row) {
+				if row[x] != before[r][x] {
+					got = append(got, row[x])
+				}
+			}
+		}
+		if tt.want != "." && tt.want != "@" && len(got) == len(tt.want) {
+			got[len(got)/2] = 'o' // whichever way it's spun
+			if tt.gold {
+				got[len(got)/2] = '@'
+			}
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: coin drawn as %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestViewDistancePlays(t *testing.T) {
+	// However far the track goes, things come in at the spawn distance and
+	// every view still draws
+	for _, view := range [][]string{{}, {"-view", "topdown"}, {"-halfblock"}} {
+		for _, far := range []string{"12", "40", "80"} {
+			g := testGame(t, 80, 24, append([]string{"-mode", "practice", "-view-distance", far, "-debug-render"}, view...)...)
+			buf := logEvents(g, "t", "lane", "kind", "step", "gold")
+			for range 600 {
+				g.update(0.05)
+				screenRows(g)
+			}
+			spawns := eventLines(buf, "spawn.obstacle", "spawn.coins")
+			if len(spawns) == 0 {
+				t.Errorf("%v view distance %s: nothing spawned", view, far)
+			}
+			want := "z=" + strconv.FormatFloat(g.cfg.spawnZ, 'g', -1, 64)
+			for _, l := range spawns {
+				if !strings.HasSuffix(l, want) {
+					t.Errorf("%v view distance %s: %q, want it at %s", view, far, l, want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestFarObstacleRendering(t *testing.T) {
+	// An obstacle stepping in from the very end of the track never draws on
+	// the horizon or into the sky, and shows from the first frame its row is
+	// below the horizon
+	for _, args := range [][]string{nil, {"-halfblock"}, {"-view-distance", "40"}} {
+		for _, shown := range []float64{0, 10} {
+			g := testGame(t, 80, 24, append([]string{"-manual", "-mode", "practice"}, args...)...)
+			for range 100 {
+				g.update(0.05) // past the opening banner
+			}
+			clearTrack(g)
+			horizon := g.height / 3
+			for z := g.cfg.farZ; z > g.cfg.farZ/2; z -= 0.25 {
+				g.obstacles[0] = obstacle{lane: 1, kind: kindBarrier, z: z, shown: shown}
+				empty := screenRows(g)
+				g.obstacles[0].active = true
+				rows := screenRows(g)
+				for r := 0; r <= horizon; r++ {
+					if rows[r] != empty[r] {
+						t.Fatalf("%q, shown %v: obstacle at z %v drew on row %d, at or above the horizon (%d):\n%s", args, shown, z, r, horizon, rows[r])
+					}
+				}
+				if z == g.cfg.farZ && !slices.Equal(rows, empty) {
+					t.Fatalf("%q, shown %v: obstacle at max z drawn", args, shown)
+				}
+				if row := g.zRow(z, horizon); row > horizon && slices.Equal(rows, empty) {
+					t.Fatalf("%q, shown %v: obstacle at z %v on row %d isn't drawn", args, shown, z, row)
+				}
+			}
+		}
+	}
+}
+
+func TestInlineRows(t *testing.T) {
+	// Inline, the game's rows start below what's kept in the scrollback, and
+	// a clear only blanks from there down
+	g := testGame(t, 80, 10, "-no-altscreen")
+	g.top = 14
+	g.redraw = true
+	frame := string(g.render())
+	for row := 1; row <= 24; row++ {
+		at := fmt.Sprintf("\033[%d;1H", row)
+		if drawn := strings.Contains(frame, at); drawn != (row > 14) {
+			t.Errorf("row %d drawn: %v", row, drawn)
+		}
+	}
+	if got, want := g.clearScreen(), "\033[15;1H\033[J"; got != want {
+		t.Errorf("clear is %q, want %q", got, want)
+	}
+	g.restart()
+	if g.top != 14 {
+		t.Errorf("a restart moved the game to row %d", g.top)
+	}
+
+	if got := testGame(t, 80, 10).clearScreen(); got != "\033[2J" {
+		t.Errorf("alt screen clear is %q", got)
+	}
+}
+
+func TestWideObstacleClipped(t *testing.T) {
+	// Near the viewer on a screen narrower than the track, the outside lanes
+	// hang off both edges. What's on screen is drawn where it really is, so
+	// the outline comes up to the edge with no post down it, and the inner
+	// post's still there.
+	tests := []struct {
+		kind     int
+		top, mid string // left edge of the top and middle rows, mirrored on the right
+		bottom   string
+	}{
+		{kindBarrier, "##", " #", "##"},
+		{kindHigh, "==", " |", " |"},
+		{kindLow, "  ", "  ", "^^"},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 14, 16, "-manual", "-mode", "practice", "-debug-render")
+		for range 100 {
+			g.update(0.05) // past the opening banner
+		}
+		clearTrack(g)
+		g.obstacles[0] = obstacle{lane: 0, kind: tt.kind, z: 1.5, shown: 10, active: true}
+		g.obstacles[1] = obstacle{lane: numLanes - 1, kind: tt.kind, z: 1.5, shown: 10, active: true}
+		rows := screenRows(g)
+		horizon := g.height / 3
+		bottom := g.zRow(1.5, horizon)
+		for i, want := range []string{tt.top, tt.mid, tt.bottom} {
+			row := rows[bottom-2+i]
+			if row[:2] != want || row[len(row)-2:] != reverse(want) {
+				t.Errorf("kind %d, row %d: %q, want it to start %q and end %q", tt.kind, bottom-2+i, row, want, reverse(want))
+			}
+		}
+	}
+
+	// Half-block clips the same way; -debug-render panics on a row that
+	// comes out the wrong width
+	for _, w := range []int{8, 14, 18} {
+		g := testGame(t, w, 16, "-manual", "-mode", "practice", "-halfblock", "-debug-render")
+		clearTrack(g)
+		for lane := range numLanes {
+			g.obstacles[lane] = obstacle{lane: lane, kind: kindBarrier, z: 0.6 + float64(lane), shown: 10, active: true}
+		}
+		screenRows(g)
+	}
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	slices.Reverse(b)
+	return string(b)
+}
+
+func TestTopSpeedLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		top, best float64
+		want      string
+	}{
+		{"faster than ever", nil, 14, 12, "TOP SPEED 14.0 (NEW BEST)"},
+		{"first run", nil, 14, 0, "TOP SPEED 14.0 (NEW BEST)"},
+		{"slower", nil, 10, 12, "TOP SPEED 10.0 (BEST 12.0)"},
+		{"faster in practice", []string{"-mode", "practice"}, 14, 12, "TOP SPEED 14.0 (BEST 12.0)"},
+		{"handicapped, no best yet", []string{"-start-speed", "12"}, 14, 0, "TOP SPEED 14.0"},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, tt.args...)
+		g.topSpeed, g.bestSpeed = tt.top, tt.best
+		if got := g.topSpeedLine(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --- Metrics ---
+//
+// -metrics :9100 serves a few numbers about the game in Prometheus' text
+// format at /metrics, for a display left running the game all day: the score
+// on screen, how many games it's played, how long it's been up and how fast
+// it's drawing. The server runs on its own goroutine, so the game loop hands
+// it a copy of the numbers after every frame rather than it reading the game
+// while it's being played.
+
+// metrics is the server and the latest numbers the game loop handed it.
+type metrics struct {
+	srv     *http.Server
+	started time.Time
+
+	mu     sync.Mutex
+	snap   metricsSnapshot
+	window time.Time // when the frames being counted toward fps started
+	frames int       // frames drawn since window
+}
+
+// metricsSnapshot is what the game loop hands over each frame.
+type metricsSnapshot struct {
+	score int
+	games int     // runs played since launch, counting each -challenge retry
+	fps   float64 // frames drawn over the last second or so
+}
+
+// serveMetrics starts serving metrics on addr, host:port or just :port. It
+// listens before returning, so a port that's taken is an error here and not
+// a surprise later. Call close when the game's done.
+func serveMetrics(addr string) (*metrics, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	m := &metrics{started: now, window: now}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.serve)
+	m.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go m.srv.Serve(ln)
+	return m, nil
+}
+
+func (m *metrics) close() error {
+	return m.srv.Close()
+}
+
+// newRun counts a run starting, a resumed one or a -challenge retry too. The
+// game's own count of tries can't be used: it's only for -challenge, and a
+// resumed run carries on counting from before launch. A nil m does nothing.
+func (m *metrics) newRun() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snap.games++
+}
+
+// publish hands the server the game's numbers after a frame's drawn. A nil m
+// does nothing, so the game loop doesn't have to check for -metrics.
+func (m *metrics) publish(g *game, now time.Time) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snap.score = g.score
+	m.frames++
+	if secs := now.Sub(m.window).Seconds(); secs >= 1 {
+		m.snap.fps = float64(m.frames) / secs
+		m.window, m.frames = now, 0
+	}
+}
+
+func (m *metrics) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	snap := m.snap
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metric := func(name, kind, help string, v any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, v)
+	}
+	metric("subway_surfer_score", "gauge", "Score of the run on screen.", snap.score)
+	metric("subway_surfer_games_total", "counter", "Runs played since launch, counting each challenge retry.", snap.games)
+	metric("subway_surfer_uptime_seconds", "gauge", "Seconds since the game started.", time.Since(m.started).Seconds())
+	metric("subway_surfer_fps", "gauge", "Frames drawn a second, over the last second.", snap.fps)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsServe(t *testing.T) {
+	m, err := serveMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.close()
+
+	// A resumed -challenge run on its fifth try, then two retries: three
+	// runs since launch
+	g := testGame(t, 80, 24)
+	g.score, g.attempt = 1234, 5
+	for range 3 {
+		m.newRun()
+	}
+	start := m.window
+	for i := range 30 {
+		m.publish(g, start.Add(time.Duration(i+1)*time.Second/20))
+	}
+
+	rec := httptest.NewRecorder()
+	m.serve(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE subway_surfer_score gauge\nsubway_surfer_score 1234\n",
+		"subway_surfer_games_total 3\n",
+		"subway_surfer_fps 20\n",
+		"# TYPE subway_surfer_uptime_seconds gauge\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("no %q in\n%s", want, body)
+		}
+	}
+
+	// A nil m is -metrics left off
+	var none *metrics
+	none.newRun()
+	none.publish(g, time.Now())
+}
+
+func TestMetricsPortTaken(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if m, err := serveMetrics(ln.Addr().String()); err == nil {
+		m.close()
+		t.Error("served on a port that's taken")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// --- Narration ---
+//
+// -narrate says what's happening as short lines of text, one event to a line,
+// for a screen reader to read out: "obstacle in left lane, dodging right",
+// "coin collected", "game over, score 12000". It listens to the same events
+// that go to -log, so it sits in front of the log's handler and passes every
+// record on.
+//
+// A screen reader can't keep up with every coin, so lines are spaced out:
+// after one goes out, anything else in the next narrateGap is dropped, bar
+// crashes and the end of a run, which always go out. Coins matter least, so
+// they never hold up anything else, and the ones picked up while it's busy
+// are counted and said together.
+
+const narrateGap = 1200 * time.Millisecond // quiet time after a line, for the reader to finish it
+
+// laneNames are the lanes as they're said.
+var laneNames = [numLanes]string{"left", "middle", "right"}
+
+// kindNames are the obstacle kinds as they're said, with what to do about
+// them when it isn't just changing lanes.
+var kindNames = [...]string{kindBarrier: "barrier", kindLow: "low wall", kindHigh: "high bar"}
+var kindMoves = [...]string{kindLow: ", jump", kindHigh: ", slide"}
+
+// narrator is a slog handler that narrates the game's events and hands every
+// record on to next.
+type narrator struct {
+	next slog.Handler
+	*narration
+}
+
+// narration is what a narrator and any loggers made from it share.
+type narration struct {
+	out      io.Writer
+	eol      string    // "\r\n" to a terminal, which is likely in raw mode
+	manual   bool      // say what's coming, since the player has to dodge it
+	last     time.Time // when the last line went out
+	lastNews time.Time // when the last line about anything but coins went out
+	coins    int       // coins picked up since the last line about coins
+}
+
+// openNarrator starts narrating to dest, stdout, stderr or a path, in front of
+// logger. Call the returned close func when the run's done.
+func openNarrator(dest string, logger *slog.Logger, manual bool) (*slog.Logger, func() error, error) {
+	f, closeOut := os.Stdout, func() error { return nil }
+	switch dest {
+	case "stdout":
+	case "stderr":
+		f = os.Stderr
+	default:
+		var err error
+		if f, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			return nil, nil, err
+		}
+		closeOut = f.Close
+	}
+	n := &narration{out: f, eol: "\n", manual: manual}
+	if term.IsTerminal(int(f.Fd())) {
+		n.eol = "\r\n"
+	}
+	return slog.New(&narrator{next: logger.Handler(), narration: n}), closeOut, nil
+}
+
+func (n *narrator) Enabled(context.Context, slog.Level) bool { return true }
+
+func (n *narrator) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &narrator{next: n.next.WithAttrs(attrs), narration: n.narration}
+}
+
+func (n *narrator) WithGroup(name string) slog.Handler {
+	return &narrator{next: n.next.WithGroup(name), narration: n.narration}
+}
+
+func (n *narrator) Handle(ctx context.Context, r slog.Record) error {
+	if n.next.Enabled(ctx, r.Level) {
+		if err := n.next.Handle(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	attrs := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.Resolve()
+		return true
+	})
+	line, urgent := n.announce(r.Message, attrs)
+	coin := r.Message == "coin"
+	switch {
+	case line == "":
+		return nil
+	case coin && r.Time.Sub(n.last) < narrateGap:
+		return nil
+	case !coin && !urgent && r.Time.Sub(n.lastNews) < narrateGap:
+		return nil
+	}
+	n.last = r.Time
+	if coin {
+		n.coins = 0
+	} else {
+		n.lastNews = r.Time
+	}
+	_, err := io.WriteString(n.out, line+n.eol)
+	return err
+}
+
+// announce is the line for an event, empty if it's not worth saying, and
+// whether it's urgent enough to skip the queue. Scores are plain digits,
+// whatever -score-format says, since those read out best.
+func (n *narration) announce(event string, attrs map[string]slog.Value) (string, bool) {
+	num := func(key string) int {
+		if v, ok := attrs[key]; ok && v.Kind() == slog.KindInt64 {
+			return int(v.Int64())
+		}
+		return 0
+	}
+	lane := func(key string) string {
+		if l := num(key); l >= 0 && l < numLanes {
+			return laneNames[l]
+		}
+		return "no"
+	}
+
+	switch event {
+	case "coin":
+		n.coins++
+		what := "coin"
+		if attrs["gold"].Kind() == slog.KindBool && attrs["gold"].Bool() {
+			what = "gold coin"
+		}
+		if n.coins > 1 {
+			what = strconv.Itoa(n.coins) + " coins"
+		}
+		return what + " collected", false
+	case "dodge":
+		from, to := num("from"), num("to")
+		switch {
+		case to < 0:
+			return fmt.Sprintf("obstacle in %s lane, nowhere to go", lane("from")), false
+		case to < from:
+			return fmt.Sprintf("obstacle in %s lane, dodging left", lane("from")), false
+		default:
+			return fmt.Sprintf("obstacle in %s lane, dodging right", lane("from")), false
+		}
+	case "pilot":
+		switch attrs["action"].String() {
+		case pilotNames[pilotJump]:
+			if attrs["gap"].Kind() == slog.KindBool && attrs["gap"].Bool() {
+				return "jumping the gap", false
+			}
+			return fmt.Sprintf("low wall in %s lane, jumping", lane("lane")), false
+		case pilotNames[pilotSlide]:
+			return fmt.Sprintf("high bar in %s lane, sliding", lane("lane")), false
+		}
+		return "", false
+	case "spawn.obstacle":
+		if !n.manual {
+			return "", false
+		}
+		kind := num("kind")
+		if kind < 0 || kind >= len(kindNames) {
+			return "", false
+		}
+		return fmt.Sprintf("%s ahead in %s lane%s", kindNames[kind], lane("lane"), kindMoves[kind]), false
+	case "closure":
+		return fmt.Sprintf("fire ahead, %s lane closing", lane("lane")), false
+	case "gap":
+		if !n.manual {
+			return "", false
+		}
+		return "gap ahead, jump", false
+	case "rush":
+		return "coin rush", false
+	case "super":
+		return fmt.Sprintf("super combo, coins times %d", superMultiplier), false
+	case "crash":
+		if attrs["over"].Kind() == slog.KindBool && attrs["over"].Bool() {
+			return fmt.Sprintf("game over, score %d", num("score")), true
+		}
+		switch attrs["mode"].String() {
+		case modeForgiving:
+			if lives := num("lives"); lives != 1 {
+				return fmt.Sprintf("crashed, %d lives left", lives), true
+			}
+			return "crashed, 1 life left", true
+		case modePractice:
+			return fmt.Sprintf("crashed, %d points off", practiceHitCost), true
+		}
+		return "crashed", true
+	case "finish":
+		return fmt.Sprintf("finished, score %d", num("score")), true
+	case "restart":
+		return fmt.Sprintf("starting over, try %d", num("attempt")), true
+	case "resume":
+		return fmt.Sprintf("carrying on, score %d", num("score")), true
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNarrator(t *testing.T) {
+	var out bytes.Buffer
+	n := &narrator{next: slog.DiscardHandler, narration: &narration{out: &out, eol: "\n", manual: true}}
+	start := time.Now()
+	events := []struct {
+		at    time.Duration
+		event string
+		attrs []slog.Attr
+	}{
+		{0, "spawn.obstacle", []slog.Attr{slog.Int("lane", 2), slog.Int("kind", kindLow)}},
+		{100 * time.Millisecond, "dodge", []slog.Attr{slog.Int("from", 1), slog.Int("to", 0)}},
+		{1300 * time.Millisecond, "dodge", []slog.Attr{slog.Int("from", 0), slog.Int("to", 1)}},
+		{1400 * time.Millisecond, "coin", []slog.Attr{slog.Bool("gold", false)}},
+		{1500 * time.Millisecond, "coin", []slog.Attr{slog.Bool("gold", true)}},
+		{1600 * time.Millisecond, "crash", []slog.Attr{slog.String("mode", modeForgiving), slog.Int("lives", 2), slog.Bool("over", false)}},
+		{1700 * time.Millisecond, "closure", []slog.Attr{slog.Int("lane", 0)}},
+		{2900 * time.Millisecond, "coin", []slog.Attr{slog.Bool("gold", false)}},
+		{3000 * time.Millisecond, "closure", []slog.Attr{slog.Int("lane", 2)}},
+		{3100 * time.Millisecond, "crash", []slog.Attr{slog.String("mode", modeForgiving), slog.Int("lives", 0), slog.Bool("over", true), slog.Int("score", 1200)}},
+	}
+	for _, e := range events {
+		r := slog.NewRecord(start.Add(e.at), slog.LevelInfo, e.event, 0)
+		r.AddAttrs(e.attrs...)
+		if err := n.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Lines are spaced out, crashes push in, and coins wait their turn and
+	// get counted up
+	want := []string{
+		"low wall ahead in right lane, jump",
+		"obstacle in left lane, dodging right",
+		"crashed, 2 lives left",
+		"3 coins collected",
+		"fire ahead, right lane closing",
+		"game over, score 1200",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("said\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestNarratorPassesOn(t *testing.T) {
+	// Every record still gets to the log behind it
+	var log bytes.Buffer
+	n := &narrator{next: slog.NewTextHandler(&log, nil), narration: &narration{out: &bytes.Buffer{}, eol: "\n"}}
+	logger := slog.New(n).With("run", 1)
+	logger.Info("coin", "gold", false)
+	logger.Info("spawn.obstacle", "lane", 0, "kind", kindBarrier)
+	if got := strings.Count(log.String(), "run=1"); got != 2 {
+		t.Errorf("log got %d of 2 records:\n%s", got, log.String())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !tune
+
+package main
+
+import "flag"
+
+// Normal builds have no tuning panel, see tune.go.
+
+func tuneFlags(*flag.FlagSet, *config) {}
+
+func (g *game) tuneKey(keyPress) bool { return false }
+
+func (g *game) tuneLines() []string { return nil }
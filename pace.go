@@ -0,0 +1,64 @@
+package main
+
+import "fmt"
+
+// --- Racing the seed best ---
+//
+// A run notes its score every paceEvery of track, and the best run on a seed
+// gets its notes kept in the stats along with its score. The next run on that
+// seed races them: the HUD says how far ahead or behind the best run was at
+// the same point on the track.
+
+const paceEvery = 20.0 // track between notes of the score
+
+// trackPace notes the score for every paceEvery of track passed since the
+// last note, the first as the run sets off.
+func (g *game) trackPace() {
+	for float64(len(g.pace))*paceEvery <= g.distance {
+		g.pace = append(g.pace, g.score)
+	}
+}
+
+// paceGap is how many points ahead of the seed best the run was at the last
+// note, behind if it's negative. It's false with no best run to race. Past
+// where the best run ended, it's raced against the score it ended on.
+//
+// Notes are compared rather than the score between them, since coins make
+// the score jump about: a run that plays just like the best reads level.
+func (g *game) paceGap() (int, bool) {
+	best := g.bestPace
+	if len(best) == 0 || len(g.pace) == 0 {
+		return 0, false
+	}
+	i := len(g.pace) - 1
+	if i >= len(best) {
+		return g.score - g.seedBest, true
+	}
+	return g.pace[i] - best[i], true
+}
+
+// paceRow is the HUD row racing the seed best, longest first.
+func (g *game) paceRow(gap int) []string {
+	if gap == 0 {
+		return []string{" LEVEL WITH BEST ", " LEVEL ", " =0 "}
+	}
+	sign, word, long := "+", "AHEAD", "AHEAD OF BEST"
+	if gap < 0 {
+		sign, word, long, gap = "-", "BEHIND", "BEHIND BEST", -gap
+	}
+	n := sign + g.cfg.scoreText(gap, false)
+	return []string{
+		fmt.Sprintf(" %s %s ", n, long),
+		fmt.Sprintf(" %s %s ", n, word),
+		fmt.Sprintf(" %s ", n),
+	}
+}
+
+// bestRun is the best score of the session's tries at the course, and its
+// pace. It's this run unless an earlier -challenge try beat it.
+func (g *game) bestRun() (int, []int) {
+	if g.bestScore > g.score {
+		return g.bestScore, g.triedPace
+	}
+	return g.score, g.pace
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPaceGap(t *testing.T) {
+	best := testGame(t, 80, 24, "-mode", "practice")
+	for range 400 {
+		best.update(0.05)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"same run", nil, 0},
+		{"head start", []string{"-start-score", "300"}, 300},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, append([]string{"-mode", "practice"}, tt.args...)...)
+		g.seedBest, g.bestPace = best.score, best.pace
+		for i := range 400 {
+			g.update(0.05)
+			if gap, ok := g.paceGap(); !ok || gap != tt.want {
+				t.Fatalf("%s: tick %d: %v ahead (%v), want %v", tt.name, i, gap, ok, tt.want)
+			}
+		}
+	}
+
+	// Without a best to race there's no row
+	g := testGame(t, 80, 24)
+	g.update(0.05)
+	if _, ok := g.paceGap(); ok {
+		t.Error("raced a best that isn't there")
+	}
+}
+
+func TestPaceRow(t *testing.T) {
+	g := testGame(t, 80, 24)
+	for gap, want := range map[int]string{0: " LEVEL WITH BEST ", 320: " +320 AHEAD OF BEST ", -1500: " -1500 BEHIND BEST "} {
+		if got := g.paceRow(gap)[0]; got != want {
+			t.Errorf("%d: got %q, want %q", gap, got, want)
+		}
+	}
+
+	g.seedBest, g.bestPace = 1000, []int{0, 100, 200}
+	g.pace, g.score = []int{0, 420}, 420
+	if !strings.Contains(strings.Join(screenRows(g), "\n"), "+320 AHEAD OF BEST") {
+		t.Error("no pace row on the HUD")
+	}
+}
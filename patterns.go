@@ -0,0 +1,79 @@
+package main
+
+// --- Obstacle patterns ---
+//
+// Now and then the spawner sends down a hand-made run of obstacles instead of
+// a random one, each testing one move. The whole pattern goes on the track
+// at once, later steps further out, and random obstacles hold off until it's
+// all in.
+
+const patternEvery = 25.0 // seconds between patterns
+
+// patternStep is one obstacle in a pattern, at seconds from the first at the
+// speed the pattern starts at, times -pattern-spacing. Steps that block lanes
+// are kept far enough apart for the autopilot to see one clear before the
+// next comes into view.
+type patternStep struct {
+	at   float64
+	lane int
+	kind int
+}
+
+type pattern struct {
+	name  string
+	steps []patternStep
+}
+
+var patterns = []pattern{
+	{"weave", []patternStep{
+		{0, 0, kindBarrier}, {0, 1, kindBarrier},
+		{2, 1, kindBarrier}, {2, 2, kindBarrier},
+		{4, 0, kindBarrier}, {4, 1, kindBarrier},
+	}},
+	{"jump-then-slide", []patternStep{
+		{0, 0, kindLow}, {0, 1, kindLow}, {0, 2, kindLow},
+		{1, 0, kindHigh}, {1, 1, kindHigh}, {1, 2, kindHigh},
+	}},
+	{"squeeze", []patternStep{
+		{0, 0, kindBarrier}, {0, 1, kindLow}, {0, 2, kindBarrier},
+		{1.5, 0, kindBarrier}, {1.5, 1, kindHigh}, {1.5, 2, kindBarrier},
+	}},
+}
+
+// maxPatternSteps is the most obstacles any pattern needs room for.
+var maxPatternSteps = func() int {
+	n := 0
+	for _, p := range patterns {
+		n = max(n, len(p.steps))
+	}
+	return n
+}()
+
+// patternFits reports whether there's room on the track for any pattern, and
+// no lane on fire to box it in.
+func (g *game) patternFits() bool {
+	obstacles, coins := g.activeObjects()
+	return obstacles+coins+maxPatternSteps <= g.cfg.maxObjects && obstacles+maxPatternSteps <= obstaclePoolSize && !g.closure.active
+}
+
+// spawnPattern picks a pattern and puts all of it on the track.
+func (g *game) spawnPattern() {
+	p := patterns[g.rng.Intn(len(patterns))]
+	j := 0
+	for _, s := range p.steps {
+		for ; j < len(g.obstacles); j++ {
+			if !g.obstacles[j].active {
+				g.obstacles[j] = obstacle{
+					lane:   s.lane,
+					kind:   s.kind,
+					z:      g.cfg.spawnZ + s.at*g.cfg.patternSpacing*g.speed,
+					active: true,
+				}
+				break
+			}
+		}
+	}
+	last := p.steps[len(p.steps)-1].at * g.cfg.patternSpacing
+	g.spawnTimer -= last
+	g.log.Info("spawn.pattern", "t", g.elapsed, "name", p.name, "secs", last)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPatternSpacing(t *testing.T) {
+	seen := map[string]bool{}
+	for _, spacing := range []float64{0.75, 1, 2.5} {
+		for seed := range 12 {
+			g := testGame(t, 80, 24, "-seed", strconv.Itoa(seed), "-pattern-spacing", strconv.FormatFloat(spacing, 'f', -1, 64))
+			clearTrack(g)
+			buf := logEvents(g, "t")
+			g.spawnPattern()
+			name := strings.Fields(eventLines(buf, "spawn.pattern")[0])[1]
+
+			var p pattern
+			for _, q := range patterns {
+				if "name="+q.name == name {
+					p = q
+					seen[q.name] = true
+				}
+			}
+			for i, s := range p.steps {
+				obs := g.obstacles[i]
+				want := g.cfg.spawnZ + s.at*spacing*g.speed
+				if !obs.active || obs.lane != s.lane || obs.kind != s.kind || math.Abs(obs.z-want) > 1e-9 {
+					t.Errorf("spacing %v, %s step %d: %+v, want lane %d kind %d at z %v", spacing, p.name, i, obs, s.lane, s.kind, want)
+				}
+			}
+			if want := -p.steps[len(p.steps)-1].at * spacing; math.Abs(g.spawnTimer-want) > 1e-9 {
+				t.Errorf("spacing %v, %s: spawns held off %v, want %v", spacing, p.name, -g.spawnTimer, -want)
+			}
+		}
+	}
+	if len(seen) != len(patterns) {
+		t.Errorf("only spawned %v", seen)
+	}
+}
+
+func TestAutopilotClearsPatterns(t *testing.T) {
+	for seed := range 12 {
+		for _, speed := range []string{"6", "20", "40"} {
+			g := testGame(t, 80, 24, "-seed", strconv.Itoa(seed), "-mode", "practice", "-start-speed", speed, "-max-speed", speed)
+			clearTrack(g)
+			buf := logEvents(g, "t")
+			g.spawnPattern()
+			for range 10 * 20 {
+				g.spawnTimer, g.coinTimer = -100, -100 // just the pattern
+				g.update(0.05)
+			}
+			if crashes := eventLines(buf, "crash"); len(crashes) > 0 {
+				t.Errorf("seed %d at speed %s: autopilot crashed into %s: %v", seed, speed, eventLines(buf, "spawn.pattern")[0], crashes)
+			}
+		}
+	}
+}
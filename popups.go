@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// --- Coin popups ---
+//
+// Every coin grabbed sends up a little "+value" over the runner's head, the
+// points it was actually worth with gold, bonus zones and the super multiplier
+// all counted in. It gets louder as the combo builds: a ! once the meter's
+// half full, and two during a super. They're only for show and aren't saved.
+
+const (
+	popupPoolSize = 4
+	popupSecs     = 0.8 // how long a popup hangs about
+	popupRise     = 3   // rows a popup floats up over its life
+)
+
+// popup is one "+value" on its way up.
+type popup struct {
+	value  int
+	marks  int     // how many !s, from the combo when it was grabbed
+	age    float64 // seconds since it went up
+	active bool
+}
+
+// popupMarks is how loud a popup for a coin grabbed now should be.
+func (g *game) popupMarks() int {
+	switch {
+	case g.superT > 0:
+		return 2
+	case g.combo*2 >= comboFull:
+		return 1
+	}
+	return 0
+}
+
+// addPopup sends up a popup for a coin worth value. Coins grabbed on the same
+// tick share one, and with the pool full the oldest makes way.
+func (g *game) addPopup(value int) {
+	slot := 0
+	for i := range g.popups {
+		p := &g.popups[i]
+		if p.active && p.age == 0 {
+			p.value += value
+			p.marks = max(p.marks, g.popupMarks())
+			return
+		}
+		if !p.active || (g.popups[slot].active && p.age > g.popups[slot].age) {
+			slot = i
+		}
+	}
+	g.popups[slot] = popup{value: value, marks: g.popupMarks(), active: true}
+}
+
+// updatePopups ages the popups and lets go of the ones that are done.
+func (g *game) updatePopups(dt float64) {
+	for i := range g.popups {
+		p := &g.popups[i]
+		if !p.active {
+			continue
+		}
+		p.age += dt
+		if p.age >= popupSecs {
+			p.active = false
+		}
+	}
+}
+
+// text is what a popup says.
+func (p popup) text() string {
+	return "+" + strconv.Itoa(p.value) + strings.Repeat("!", p.marks)
+}
+
+// popupSpot is the column over the runner's head and the row just above it,
+// where popups start from.
+func (g *game) popupSpot(horizon int) (x, row int) {
+	if g.cfg.view == viewTopDown {
+		lw := (g.trackCols() - 2) / numLanes
+		return g.topDownLaneX(g.snap(g.laneX)) + lw/2, g.topDownRunnerRow() - 2
+	}
+	x, feet := g.runnerSpot(horizon)
+	return x, feet - g.jumpLift() - 3
+}
+
+// drawPopups draws any popups on this row. With -reduced-motion they stay put
+// rather than floating up.
+func (g *game) drawPopups(buf []byte, row, horizon int) {
+	x, base := g.popupSpot(horizon)
+	for _, p := range g.popups {
+		if !p.active {
+			continue
+		}
+		at := base
+		if !g.cfg.reducedMotion {
+			at -= int(p.age / popupSecs * popupRise)
+		}
+		if row == at {
+			s := p.text()
+			placeString(buf, x-len(s)/2, s)
+		}
+	}
+}
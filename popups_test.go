@@ -0,0 +1,83 @@
+package main
+
+import "testing"
+
+func TestPopupValue(t *testing.T) {
+	// -coin-value 10, so a popup's 10 times gold (5), a bonus zone (2) and a
+	// super (3), as they stand when the coin's grabbed
+	tests := []struct {
+		name       string
+		gold, zone bool
+		superT     float64
+		combo      int
+		coins      int // grabbed in the same tick
+		value      int
+		marks      int
+		superAfter bool
+	}{
+		{"plain", false, false, 0, 0, 1, 10, 0, false},
+		{"gold", true, false, 0, 0, 1, 50, 0, false},
+		{"bonus zone", false, true, 0, 0, 1, 20, 0, false},
+		{"super", false, false, 1, 0, 1, 30, 2, true},
+		{"gold in a zone on a super", true, true, 1, 0, 1, 300, 2, true},
+		{"meter half full", false, false, 0, comboFull / 2, 1, 10, 1, false},
+		// The coin that fills the meter isn't worth the super it sets off
+		{"fills the meter", false, false, 0, comboFull - comboFill, 1, 10, 1, true},
+		{"two at once", false, false, 0, 0, 2, 20, 0, false},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-manual", "-coin-value", "10")
+		clearTrack(g)
+		g.spawnTimer, g.coinTimer = -100, -100
+		g.superT, g.combo = tt.superT, tt.combo
+		if tt.zone {
+			g.zone = bonusZone{start: runnerZ - 1, end: runnerZ + 10, active: true}
+		}
+		for i := range tt.coins {
+			g.coinPool[i] = coinObj{lane: g.runnerLane, x: float64(g.runnerLane), gold: tt.gold, z: 0.5, active: true}
+		}
+		score := g.score
+		g.update(0.01)
+
+		var up []popup
+		for _, p := range g.popups {
+			if p.active {
+				up = append(up, p)
+			}
+		}
+		if len(up) != 1 {
+			t.Errorf("%s: %d popups, want 1", tt.name, len(up))
+			continue
+		}
+		if up[0].value != tt.value || up[0].marks != tt.marks {
+			t.Errorf("%s: popup %q, want value %d with %d marks", tt.name, up[0].text(), tt.value, tt.marks)
+		}
+		if g.score-score != up[0].value {
+			t.Errorf("%s: scored %d, popup says %d", tt.name, g.score-score, up[0].value)
+		}
+		if super := g.superT > 0; super != tt.superAfter {
+			t.Errorf("%s: super after the grab %v, want %v", tt.name, super, tt.superAfter)
+		}
+	}
+}
+
+func TestPopupPool(t *testing.T) {
+	g := testGame(t, 80, 24)
+	for i := range popupPoolSize + 1 {
+		g.addPopup(i + 1)
+		g.updatePopups(0.1)
+	}
+	// The oldest, +1, made way for the newest
+	for _, p := range g.popups {
+		if !p.active || p.value == 1 {
+			t.Errorf("popups %+v, want 2 to %d", g.popups, popupPoolSize+1)
+			break
+		}
+	}
+	g.updatePopups(popupSecs)
+	for _, p := range g.popups {
+		if p.active {
+			t.Errorf("popup %q still up after %vs", p.text(), popupSecs)
+		}
+	}
+}
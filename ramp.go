@@ -0,0 +1,30 @@
+package main
+
+// --- Speed ramp ---
+//
+// By default the speed goes up with time spent running. -ramp distance goes
+// by track covered instead, and -ramp score by points, so the run gets harder
+// with how far you get rather than how long you've been at it. To keep
+// -speed-ramp meaning about the same either way, it's then per second's worth
+// of running at the start speed: that much track, or the points that much
+// track is worth. Going faster covers ground quicker, so both of those climb
+// faster the faster you're going, and -max-speed matters more.
+
+// Ramp sources
+const (
+	rampTime     = "time"
+	rampDistance = "distance"
+	rampScore    = "score"
+)
+
+// rampSpeed is the speed the run's ramped up to, before any cap.
+func (g *game) rampSpeed() float64 {
+	secs := g.elapsed
+	switch g.cfg.rampBy {
+	case rampDistance:
+		secs = g.distance / g.cfg.baseSpeed
+	case rampScore:
+		secs = float64(g.score-g.cfg.startScore) / (g.cfg.baseSpeed * g.cfg.distanceValue)
+	}
+	return g.cfg.baseSpeed + max(secs, 0)*g.cfg.speedRamp
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestRampSpeed(t *testing.T) {
+	// -speed-ramp 0.5 on a start speed of 10 with track worth 2 points, so a
+	// second's worth is 10 track or 20 points, and each is worth 0.5
+	tests := []struct {
+		ramp     string
+		elapsed  float64
+		distance float64
+		score    int
+		start    int // -start-score
+		want     float64
+	}{
+		{rampTime, 0, 0, 0, 0, 10},
+		{rampTime, 4, 0, 0, 0, 12},
+		{rampTime, 4, 1000, 5000, 0, 12},
+		{rampDistance, 100, 0, 0, 0, 10},
+		{rampDistance, 100, 40, 0, 0, 12},
+		{rampDistance, 0, 40, 5000, 0, 12},
+		{rampScore, 100, 1000, 80, 0, 12},
+		{rampScore, 0, 0, 80, 0, 12},
+		{rampScore, 0, 0, 80, 40, 11}, // what -start-score gave doesn't count
+		{rampScore, 0, 0, 20, 40, 10}, // nor does going under it in practice
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, "-ramp", tt.ramp, "-start-speed", "10", "-speed-ramp", "0.5", "-distance-value", "2", "-max-speed", "0", "-start-score", strconv.Itoa(tt.start))
+		g.elapsed, g.distance, g.score = tt.elapsed, tt.distance, tt.score
+		if got := g.rampSpeed(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("-ramp %s, elapsed %v, distance %v, score %d: speed %v, want %v", tt.ramp, tt.elapsed, tt.distance, tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestRampUnaffectedByPause(t *testing.T) {
+	for _, ramp := range []string{rampTime, rampDistance, rampScore} {
+		args := []string{"-mode", "practice", "-ramp", ramp, "-max-speed", "0"}
+		g, ref := testGame(t, 80, 24, args...), testGame(t, 80, 24, args...)
+		for range 100 {
+			g.update(0.05)
+			ref.update(0.05)
+		}
+		speed := g.speed
+		g.paused = true
+		for range 400 {
+			g.update(0.05)
+		}
+		if g.speed != speed {
+			t.Errorf("-ramp %s: speed went from %v to %v while paused", ramp, speed, g.speed)
+		}
+		g.paused = false
+		for range 100 {
+			g.update(0.05)
+			ref.update(0.05)
+		}
+		if g.speed != ref.speed || g.speed <= speed {
+			t.Errorf("-ramp %s: speed %v after a pause, %v without one, %v before it", ramp, g.speed, ref.speed, speed)
+		}
+	}
+}
+
+func TestRampByProgress(t *testing.T) {
+	// Standing time still while the track goes by, or coins coming in, only
+	// moves the speed for the ramps that go by them
+	for _, tt := range []struct {
+		ramp             string
+		byTrack, byCoins bool
+	}{
+		{rampTime, false, false},
+		{rampDistance, true, false},
+		{rampScore, true, true},
+	} {
+		g := testGame(t, 80, 24, "-mode", "practice", "-ramp", tt.ramp, "-max-speed", "0")
+		g.update(0.05)
+		speed := g.rampSpeed()
+		g.distance += 100
+		g.score += int(100 * g.cfg.distanceValue)
+		if moved := g.rampSpeed() > speed; moved != tt.byTrack {
+			t.Errorf("-ramp %s: 100 track on, speed moved %v, want %v", tt.ramp, moved, tt.byTrack)
+		}
+		speed = g.rampSpeed()
+		g.score += 50 * g.cfg.coinValue
+		if moved := g.rampSpeed() > speed; moved != tt.byCoins {
+			t.Errorf("-ramp %s: 50 coins on, speed moved %v, want %v", tt.ramp, moved, tt.byCoins)
+		}
+	}
+}
+
+func TestRampFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{"-ramp", "coins"},
+		{"-ramp", "score", "-distance-value", "0"},
+		{"-speed-ramp", "-0.1"},
+	} {
+		if _, err := parseConfig(args); err == nil {
+			t.Errorf("%q: took it", args)
+		}
+	}
+}
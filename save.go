@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// --- Save and resume ---
+//
+// Quitting partway through a run saves it, and -resume carries on from exactly
+// there next launch: same flags, same course, every object where it was. The
+// RNG can't be written out, so the save keeps the seed and how many draws the
+// run has made, and resuming makes that many again from the seed to catch up.
+
+// saveVersion goes up whenever savedGame changes shape. A save from any other
+// version is refused, not guessed at.
+const saveVersion = 7
+
+// countingSource is a rand.Source that counts its draws, so a saved game can
+// put its RNG back where it was.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func seedSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.draws = 0
+}
+
+type savedObstacle struct {
+	Lane   int     `json:"lane"`
+	Kind   int     `json:"kind"`
+	Z      float64 `json:"z"`
+	Shown  float64 `json:"shown"`
+	Active bool    `json:"active"`
+}
+
+type savedCoin struct {
+	Lane   int     `json:"lane"`
+	X      float64 `json:"x"`
+	Z      float64 `json:"z"`
+	Gold   bool    `json:"gold"`
+	Active bool    `json:"active"`
+}
+
+type savedClosure struct {
+	Lane   int     `json:"lane"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Active bool    `json:"active"`
+}
+
+type savedGap struct {
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Active bool    `json:"active"`
+}
+
+type savedZone struct {
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Active bool    `json:"active"`
+}
+
+// savedGame is everything about a run that changes as it plays. The object
+// pools go in whole, empty slots too, since which slot a spawn lands in
+// depends on them.
+type savedGame struct {
+	Version int      `json:"version"`
+	Args    []string `json:"args"` // flags the run was started with
+	Seed    int64    `json:"seed"`
+	Draws   uint64   `json:"draws"`
+
+	Attempt      int             `json:"attempt"`
+	BestScore    int             `json:"best_score"`
+	Pace         []int           `json:"pace"`
+	TriedPace    []int           `json:"tried_pace"`
+	Speeds       []float64       `json:"speeds"`
+	Speed        float64         `json:"speed"`
+	TopSpeed     float64         `json:"top_speed"`
+	Score        int             `json:"score"`
+	Coins        int             `json:"coins"`
+	Lives        int             `json:"lives"`
+	RunnerLane   int             `json:"runner_lane"`
+	TargetLane   int             `json:"target_lane"`
+	SteeredAt    float64         `json:"steered_at"`
+	LaneX        float64         `json:"lane_x"`
+	LaneFrom     float64         `json:"lane_from"`
+	LaneT        float64         `json:"lane_t"`
+	JumpT        float64         `json:"jump_t"`
+	SlideT       float64         `json:"slide_t"`
+	Stride       float64         `json:"stride"`
+	Obstacles    []savedObstacle `json:"obstacles"`
+	CoinPool     []savedCoin     `json:"coin_pool"`
+	ScrollOff    float64         `json:"scroll_off"`
+	Distance     float64         `json:"distance"`
+	Curve        float64         `json:"curve"`
+	Shake        float64         `json:"shake"`
+	Elapsed      float64         `json:"elapsed"`
+	SpawnTimer   float64         `json:"spawn_timer"`
+	PatternTimer float64         `json:"pattern_timer"`
+	CourseNext   int             `json:"course_next"`
+	CoinTimer    float64         `json:"coin_timer"`
+	WavePhase    float64         `json:"wave_phase"`
+	Zone         savedZone       `json:"zone"`
+	ZoneTimer    float64         `json:"zone_timer"`
+	Closure      savedClosure    `json:"closure"`
+	ClosureTimer float64         `json:"closure_timer"`
+	Gap          savedGap        `json:"gap"`
+	GapTimer     float64         `json:"gap_timer"`
+	RushTimer    float64         `json:"rush_timer"`
+	RushT        float64         `json:"rush_t"`
+	Combo        int             `json:"combo"`
+	SuperT       float64         `json:"super_t"`
+}
+
+func savePath() (string, error) {
+	return configFile("save.json")
+}
+
+// canSave reports whether quitting now should save the run: it's under way
+// and not over, someone's playing it, and it has a seed to rebuild the course
+// from. A run quit before it got going doesn't write over an older save.
+func (g *game) canSave() bool {
+	return g.elapsed > 0 && !g.over && !g.finished && !g.cfg.demo && g.src != nil
+}
+
+// saved is the run as it stands, ready to write out.
+func (g *game) saved() savedGame {
+	sv := savedGame{
+		Version: saveVersion,
+		Args:    g.cfg.args,
+		Seed:    g.seed,
+		Draws:   g.src.draws,
+
+		Attempt:      g.attempt,
+		BestScore:    g.bestScore,
+		Pace:         g.pace,
+		TriedPace:    g.triedPace,
+		Speeds:       g.speeds,
+		Speed:        g.speed,
+		TopSpeed:     g.topSpeed,
+		Score:        g.score,
+		Coins:        g.coins,
+		Lives:        g.lives,
+		RunnerLane:   g.runnerLane,
+		TargetLane:   g.targetLane,
+		SteeredAt:    g.steeredAt,
+		LaneX:        g.laneX,
+		LaneFrom:     g.laneFrom,
+		LaneT:        g.laneT,
+		JumpT:        g.jumpT,
+		SlideT:       g.slideT,
+		Stride:       g.stride,
+		ScrollOff:    g.scrollOff,
+		Distance:     g.distance,
+		Curve:        g.curve,
+		Shake:        g.shake,
+		Elapsed:      g.elapsed,
+		SpawnTimer:   g.spawnTimer,
+		PatternTimer: g.patternTimer,
+		CourseNext:   g.courseNext,
+		CoinTimer:    g.coinTimer,
+		WavePhase:    g.wavePhase,
+		Zone:         savedZone{g.zone.start, g.zone.end, g.zone.active},
+		ZoneTimer:    g.zoneTimer,
+		Closure:      savedClosure{g.closure.lane, g.closure.start, g.closure.end, g.closure.active},
+		ClosureTimer: g.closureTimer,
+		Gap:          savedGap{g.gap.start, g.gap.end, g.gap.active},
+		GapTimer:     g.gapTimer,
+		RushTimer:    g.rushTimer,
+		RushT:        g.rushT,
+		Combo:        g.combo,
+		SuperT:       g.superT,
+	}
+	for _, o := range g.obstacles {
+		sv.Obstacles = append(sv.Obstacles, savedObstacle{o.lane, o.kind, o.z, o.shown, o.active})
+	}
+	for _, c := range g.coinPool {
+		sv.CoinPool = append(sv.CoinPool, savedCoin{c.lane, c.x, c.z, c.gold, c.active})
+	}
+	return sv
+}
+
+// save writes the run to the save file, over any save already there.
+func (g *game) save() error {
+	path, err := savePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(g.saved(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// loadSave reads the save file and takes it off disk, so a run can only be
+// resumed once. The config's the one the run was saved with.
+func loadSave() (*savedGame, config, error) {
+	path, err := savePath()
+	if err != nil {
+		return nil, config{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, config{}, errors.New("no saved run, quit partway through one to save it")
+	}
+	if err != nil {
+		return nil, config{}, err
+	}
+
+	// The version's checked on its own first, so a save from another build
+	// gets a straight answer instead of whatever its fields decode to
+	var v struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, config{}, fmt.Errorf("save is damaged: %w", err)
+	}
+	if v.Version != saveVersion {
+		return nil, config{}, fmt.Errorf("save is version %d, this build only reads version %d", v.Version, saveVersion)
+	}
+	sv := &savedGame{}
+	if err := json.Unmarshal(data, sv); err != nil {
+		return nil, config{}, fmt.Errorf("save is damaged: %w", err)
+	}
+	cfg, err := parseConfig(sv.Args)
+	if err != nil {
+		return nil, config{}, fmt.Errorf("the saved flags don't work any more: %w", err)
+	}
+	if err := sv.check(cfg); err != nil {
+		return nil, config{}, fmt.Errorf("save is damaged: %w", err)
+	}
+	return sv, cfg, os.Remove(path)
+}
+
+// check catches anything in a save that would send a game on cfg out of
+// bounds, or that no run on it could have got to.
+func (sv *savedGame) check(cfg config) error {
+	if len(sv.Obstacles) != obstaclePoolSize || len(sv.CoinPool) != coinPoolSize {
+		return fmt.Errorf("want %d obstacles and %d coins, got %d and %d", obstaclePoolSize, coinPoolSize, len(sv.Obstacles), len(sv.CoinPool))
+	}
+	lanes := []int{sv.RunnerLane, sv.TargetLane, sv.Closure.Lane}
+	for _, o := range sv.Obstacles {
+		if o.Kind < kindBarrier || o.Kind > kindHigh {
+			return fmt.Errorf("unknown obstacle kind %d", o.Kind)
+		}
+		lanes = append(lanes, o.Lane)
+	}
+	for _, c := range sv.CoinPool {
+		if c.X < 0 || c.X > numLanes-1 {
+			return fmt.Errorf("coin drawn off the track at %v", c.X)
+		}
+		lanes = append(lanes, c.Lane)
+	}
+	for _, lane := range lanes {
+		if lane < 0 || lane >= numLanes {
+			return fmt.Errorf("lane %d is off the track", lane)
+		}
+	}
+	// An overshoot swings a little past the outside lanes, never half a lane
+	for _, x := range []float64{sv.LaneX, sv.LaneFrom} {
+		if x < -0.5 || x > numLanes-0.5 {
+			return fmt.Errorf("runner off the track at %v", x)
+		}
+	}
+	if sv.LaneT < 0 || sv.LaneT > 1 {
+		return fmt.Errorf("lane change %v of the way through", sv.LaneT)
+	}
+	if sv.CourseNext < 0 {
+		return fmt.Errorf("course spawn %d doesn't exist", sv.CourseNext)
+	}
+	if sv.Combo < 0 || sv.Combo > comboFull {
+		return fmt.Errorf("combo must be from 0 to %d, got %d", comboFull, sv.Combo)
+	}
+	// A saved run isn't over, so it has a life left, and never more than it
+	// started with
+	if sv.Lives < 1 || sv.Lives > cfg.lives {
+		return fmt.Errorf("lives must be from 1 to %d, got %d", cfg.lives, sv.Lives)
+	}
+	if sv.Score < 0 || sv.BestScore < 0 || sv.Coins < 0 || sv.Attempt < 1 {
+		return fmt.Errorf("score %d, best %d, coins %d, try %d can't be", sv.Score, sv.BestScore, sv.Coins, sv.Attempt)
+	}
+	if sv.Speed <= 0 || cfg.maxSpeed > 0 && sv.Speed > cfg.maxSpeed {
+		return fmt.Errorf("speed %v is out of range", sv.Speed)
+	}
+
+	// Timers that count down run from their full length, ones that count up
+	// only move with the run so can't have gone longer than it
+	timers := []struct {
+		name      string
+		t, lo, hi float64
+	}{
+		{"elapsed", sv.Elapsed, 0, math.MaxFloat64},
+		{"distance", sv.Distance, 0, math.MaxFloat64},
+		{"jump", sv.JumpT, 0, cfg.jumpSecs},
+		{"slide", sv.SlideT, 0, actionSecs},
+		{"shake", sv.Shake, 0, shakeSecs},
+		{"super", sv.SuperT, 0, superSecs},
+		{"rush", sv.RushT, 0, cfg.rushSecs},
+		{"stride", sv.Stride, 0, 4},
+		{"curve", sv.Curve, -1, 1},
+		{"steered at", sv.SteeredAt, 0, sv.Elapsed},
+		{"pattern timer", sv.PatternTimer, 0, sv.Elapsed},
+		{"coin timer", sv.CoinTimer, 0, sv.Elapsed},
+		{"zone timer", sv.ZoneTimer, 0, sv.Elapsed},
+		{"closure timer", sv.ClosureTimer, 0, sv.Elapsed},
+		{"gap timer", sv.GapTimer, 0, sv.Elapsed},
+		{"rush timer", sv.RushTimer, 0, sv.Elapsed},
+		{"spawn timer", sv.SpawnTimer, -math.MaxFloat64, sv.Elapsed}, // patterns push it back
+	}
+	for _, tm := range timers {
+		if tm.t < tm.lo || tm.t > tm.hi {
+			return fmt.Errorf("%s %v isn't from %v to %v", tm.name, tm.t, tm.lo, tm.hi)
+		}
+	}
+	return nil
+}
+
+// restore puts g back where the saved run was. The screen and anything else
+// about the session stays as g has it.
+func (sv *savedGame) restore(g *game) {
+	src := seedSource(sv.Seed)
+	for i := uint64(0); i < sv.Draws; i++ {
+		src.Int63()
+	}
+	g.seed, g.src, g.rng = sv.Seed, src, rand.New(src)
+
+	g.attempt = sv.Attempt
+	g.bestScore = sv.BestScore
+	g.pace = sv.Pace
+	g.speeds = sv.Speeds
+	g.triedPace = sv.TriedPace
+	g.speed = sv.Speed
+	g.topSpeed = sv.TopSpeed
+	g.score = sv.Score
+	g.coins = sv.Coins
+	g.lives = sv.Lives
+	g.runnerLane = sv.RunnerLane
+	g.targetLane = sv.TargetLane
+	g.steeredAt = sv.SteeredAt
+	g.laneX = sv.LaneX
+	g.laneFrom, g.laneT = sv.LaneFrom, sv.LaneT
+	g.jumpT = sv.JumpT
+	g.slideT = sv.SlideT
+	g.stride = sv.Stride
+	for i, o := range sv.Obstacles {
+		g.obstacles[i] = obstacle{lane: o.Lane, kind: o.Kind, z: o.Z, shown: o.Shown, active: o.Active}
+	}
+	for i, c := range sv.CoinPool {
+		g.coinPool[i] = coinObj{lane: c.Lane, x: c.X, z: c.Z, gold: c.Gold, active: c.Active}
+	}
+	g.scrollOff = sv.ScrollOff
+	g.distance = sv.Distance
+	g.curve = sv.Curve
+	g.shake = sv.Shake
+	g.elapsed = sv.Elapsed
+	g.spawnTimer = sv.SpawnTimer
+	g.patternTimer = sv.PatternTimer
+	g.courseNext = sv.CourseNext
+	g.coinTimer = sv.CoinTimer
+	g.wavePhase = sv.WavePhase
+	g.zone = bonusZone{start: sv.Zone.Start, end: sv.Zone.End, active: sv.Zone.Active}
+	g.zoneTimer = sv.ZoneTimer
+	g.closure = closure{lane: sv.Closure.Lane, start: sv.Closure.Start, end: sv.Closure.End, active: sv.Closure.Active}
+	g.closureTimer = sv.ClosureTimer
+	g.gap = gap{start: sv.Gap.Start, end: sv.Gap.End, active: sv.Gap.Active}
+	g.gapTimer = sv.GapTimer
+	g.rushTimer = sv.RushTimer
+	g.rushT = sv.RushT
+	g.combo = sv.Combo
+	g.superT = sv.SuperT
+}
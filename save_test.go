@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLoadSave(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	g := testGame(t, 80, 24, "-mode", "practice")
+	for range 400 {
+		g.update(0.05)
+	}
+	if err := g.save(); err != nil {
+		t.Fatal(err)
+	}
+	sv, cfg, err := loadSave()
+	if err != nil {
+		t.Fatalf("loadSave: %v", err)
+	}
+	if _, _, err := loadSave(); err == nil {
+		t.Error("the save loaded twice")
+	}
+
+	// Resuming from the save carries on the run it came from, draw for draw
+	h := newGame(80, 24, cfg)
+	h.countdown = 0
+	sv.restore(h)
+	for i := range 600 {
+		g.update(0.05)
+		h.update(0.05)
+		if g.score != h.score || g.distance != h.distance || g.obstacles != h.obstacles || g.coinPool != h.coinPool {
+			t.Fatalf("tick %d: the resumed run went its own way", i)
+		}
+	}
+	if h.src.draws != g.src.draws {
+		t.Errorf("resumed run made %d draws, the one it came from %d", h.src.draws, g.src.draws)
+	}
+
+	// A save with a life too many doesn't load, and stays put
+	g = testGame(t, 80, 24, "-mode", "forgiving", "-lives", "2")
+	g.lives = 3
+	if err := g.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadSave(); err == nil {
+		t.Error("loaded a save with more lives than the run started with")
+	}
+	path, err := savePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("a save that didn't load was taken off disk: %v", err)
+	}
+}
+
+func TestLoadSaveRefuses(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"another version", `{"version": 99}`, "version 99"},
+		{"not json", `{"version": `, "damaged"},
+		{"pools missing", fmt.Sprintf(`{"version": %d}`, saveVersion), "damaged"},
+	}
+	for _, tt := range tests {
+		t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+		path, err := savePath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := loadSave(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: loadSave = %v, want an error about %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSaveCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		spoil func(sv *savedGame)
+		ok    bool
+	}{
+		{"as saved", func(sv *savedGame) {}, true},
+		{"no lives", func(sv *savedGame) { sv.Lives = 0 }, false},
+		{"negative lives", func(sv *savedGame) { sv.Lives = -1 }, false},
+		{"more lives than it started with", func(sv *savedGame) { sv.Lives = 4 }, false},
+		{"negative score", func(sv *savedGame) { sv.Score = -5 }, false},
+		{"negative coins", func(sv *savedGame) { sv.Coins = -1 }, false},
+		{"no tries", func(sv *savedGame) { sv.Attempt = 0 }, false},
+		{"stopped", func(sv *savedGame) { sv.Speed = 0 }, false},
+		{"over the cap", func(sv *savedGame) { sv.Speed = 17 }, false},
+		{"negative elapsed", func(sv *savedGame) { sv.Elapsed = -1 }, false},
+		{"jump longer than a jump", func(sv *savedGame) { sv.JumpT = 5 }, false},
+		{"negative slide", func(sv *savedGame) { sv.SlideT = -0.1 }, false},
+		{"super longer than a super", func(sv *savedGame) { sv.SuperT = 1e9 }, false},
+		{"rush longer than a rush", func(sv *savedGame) { sv.RushT = 60 }, false},
+		{"stride off the cycle", func(sv *savedGame) { sv.Stride = 4.5 }, false},
+		{"curve past full", func(sv *savedGame) { sv.Curve = -2 }, false},
+		{"steered in the future", func(sv *savedGame) { sv.SteeredAt = sv.Elapsed + 1 }, false},
+		{"timer older than the run", func(sv *savedGame) { sv.ClosureTimer = sv.Elapsed + 1 }, false},
+		{"spawn timer pushed back", func(sv *savedGame) { sv.SpawnTimer = -3 }, true},
+	}
+	g := testGame(t, 80, 24, "-mode", "forgiving", "-lives", "3")
+	for range 200 {
+		g.update(0.05)
+	}
+	for _, tt := range tests {
+		sv := g.saved()
+		tt.spoil(&sv)
+		if err := sv.check(g.cfg); (err == nil) != tt.ok {
+			t.Errorf("%s: check = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestSaveCheckPassesRealRuns(t *testing.T) {
+	// Whatever a run gets up to, quitting at any point saves something that
+	// loads back
+	for seed := range 8 {
+		mode := []string{modeForgiving, modePractice}[seed%2]
+		g := testGame(t, 80, 24, "-seed", strconv.Itoa(seed), "-mode", mode, "-closure-every", "4", "-gap-every", "5", "-max-speed", "0")
+		for i := range 3000 {
+			g.update(0.05)
+			if !g.canSave() {
+				break
+			}
+			if sv := g.saved(); i%10 == 0 {
+				if err := sv.check(g.cfg); err != nil {
+					t.Fatalf("seed %d, tick %d: %v", seed, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestSaveCheckLanes(t *testing.T) {
+	g := testGame(t, 80, 24)
+	for range 200 {
+		g.update(0.05)
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	g.runnerLane = numLanes
+	if err := g.save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadSave(); err == nil {
+		t.Error("loaded a save with the runner off the track")
+	}
+}
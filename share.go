@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// --- Share card ---
+//
+// When a run ends, a little box goes under the summary panel with the score,
+// coins, distance and seed, and a sparkline of the speed over the run, to
+// copy and paste wherever you're bragging. It's plain ASCII so it pastes
+// anywhere, and it's left off rather than cut off if the terminal can't fit
+// it. -share-card PATH writes it to a file too, if a run ended.
+
+const (
+	speedEvery = 1.0 // seconds of running between notes of the speed
+	sparkCols  = 20  // how wide the sparkline is, when there's room
+	minSpark   = 6   // narrowest the sparkline gets before the card's left off
+)
+
+// sparkChars are the sparkline's levels, lowest first.
+const sparkChars = "_.-=+*#"
+
+// trackSpeed notes the speed for every speedEvery of running since the last
+// note, the first as the run sets off.
+func (g *game) trackSpeed() {
+	for float64(len(g.speeds))*speedEvery <= g.elapsed {
+		g.speeds = append(g.speeds, g.speed)
+	}
+}
+
+// sparkline draws the speed notes cols wide, fewer if there aren't that many
+// notes, each column the average of its share of them. The slowest column
+// sits at the bottom and the fastest at the top, so even a short run shows
+// its shape.
+func (g *game) sparkline(cols int) string {
+	n := len(g.speeds)
+	cols = min(cols, n)
+	avgs := make([]float64, cols)
+	lo, hi := 0.0, 0.0
+	for c := range avgs {
+		notes := g.speeds[c*n/cols : (c+1)*n/cols]
+		for _, s := range notes {
+			avgs[c] += s
+		}
+		avgs[c] /= float64(len(notes))
+		if c == 0 || avgs[c] < lo {
+			lo = avgs[c]
+		}
+		if c == 0 || avgs[c] > hi {
+			hi = avgs[c]
+		}
+	}
+	line := make([]byte, cols)
+	for c, a := range avgs {
+		level := len(sparkChars) / 2
+		if hi > lo {
+			level = int((a - lo) / (hi - lo) * float64(len(sparkChars)-1))
+		}
+		line[c] = sparkChars[level]
+	}
+	return string(line)
+}
+
+// shareCard is the boxed card for the run, no wider than width, or nil if it
+// can't fit.
+func (g *game) shareCard(width int) []string {
+	stats := []string{
+		fmt.Sprintf("SUBWAY SURFER  seed %d", g.seed),
+		fmt.Sprintf("score %s  coins %d", g.cfg.scoreText(g.score, false), g.coins),
+		fmt.Sprintf("distance %d  time %.0fs", int(g.distance), g.elapsed),
+	}
+	inner := 0
+	for _, l := range stats {
+		inner = max(inner, len(l))
+	}
+	const speedLabel = "speed "
+	spark := min(sparkCols, width-4-len(speedLabel))
+	if spark < minSpark {
+		return nil
+	}
+	body := append(stats, speedLabel+g.sparkline(spark))
+	inner = max(inner, len(body[len(body)-1]))
+	if inner+4 > width {
+		return nil
+	}
+
+	border := "+" + strings.Repeat("-", inner+2) + "+"
+	lines := []string{border}
+	for _, l := range body {
+		lines = append(lines, "| "+l+strings.Repeat(" ", inner-len(l))+" |")
+	}
+	return append(lines, border)
+}
+
+// writeShareCard writes the run's share card to path, as wide as it would be
+// on an 80 column screen. Quitting halfway through a run leaves nothing to
+// brag about yet, and a card that doesn't fit even 80 columns can't be made,
+// so both are errors, not an empty file.
+func (g *game) writeShareCard(path string) error {
+	if !g.ended() {
+		return errors.New("the run hadn't ended")
+	}
+	card := g.shareCard(defaultWidth)
+	if card == nil {
+		return fmt.Errorf("the share card doesn't fit in %d columns", defaultWidth)
+	}
+	return os.WriteFile(path, []byte(strings.Join(card, "\n")+"\n"), 0o644)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		speeds []float64
+		cols   int
+		want   string
+	}{
+		{nil, 10, ""},
+		{[]float64{8, 8, 8}, 10, "==="},
+		{[]float64{1, 2, 3, 4, 5, 6, 7}, 7, sparkChars},
+		{[]float64{7, 6, 5, 4, 3, 2, 1}, 7, "#*+=-._"},
+		{[]float64{1, 1, 2, 2, 3, 3}, 3, "_=#"},
+		{[]float64{1, 2, 3, 4, 5, 6, 7}, 2, "_#"},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24)
+		g.speeds = tt.speeds
+		if got := g.sparkline(tt.cols); got != tt.want {
+			t.Errorf("%v in %d: %q, want %q", tt.speeds, tt.cols, got, tt.want)
+		}
+	}
+}
+
+func TestShareCard(t *testing.T) {
+	g := testGame(t, 80, 24)
+	g.score, g.coins, g.distance, g.elapsed = 1234, 56, 789.5, 61
+	g.speeds = []float64{8, 9, 10, 11}
+	want := []string{
+		"+------------------------+",
+		"| SUBWAY SURFER  seed 1  |",
+		"| score 1234  coins 56   |",
+		"| distance 789  time 61s |",
+		"| speed _-+#             |",
+		"+------------------------+",
+	}
+	for _, width := range []int{80, 26} {
+		if got := g.shareCard(width); !slices.Equal(got, want) {
+			t.Errorf("at %d columns:\n%s\nwant:\n%s", width, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+	// Too narrow for the box, or for enough of a sparkline
+	for _, width := range []int{25, 4 + len("speed ") + minSpark - 1, 0} {
+		if got := g.shareCard(width); got != nil {
+			t.Errorf("at %d columns: %q, want it left off", width, got)
+		}
+	}
+}
+
+func TestWriteShareCard(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *game)
+		err   string
+	}{
+		{"game over", func(g *game) { g.over = true }, ""},
+		{"level finished", func(g *game) { g.finished = true }, ""},
+		{"quit halfway", func(g *game) {}, "hadn't ended"},
+		{"too wide", func(g *game) { g.over, g.elapsed = true, 1e80 }, "doesn't fit"},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24)
+		g.speeds = []float64{8, 9}
+		tt.setup(g)
+		path := filepath.Join(t.TempDir(), "card.txt")
+		err := g.writeShareCard(path)
+		data, readErr := os.ReadFile(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want one about %q", tt.name, err, tt.err)
+			}
+			if readErr == nil {
+				t.Errorf("%s: wrote %q anyway", tt.name, data)
+			}
+			continue
+		}
+		if err != nil || readErr != nil {
+			t.Fatalf("%s: %v, %v", tt.name, err, readErr)
+		}
+		if want := strings.Join(g.shareCard(defaultWidth), "\n") + "\n"; string(data) != want {
+			t.Errorf("%s: wrote %q, want %q", tt.name, data, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// --- Persisted stats ---
+
+type stats struct {
+	// Best score for each seeded course, keyed by seedKey
+	SeedBests map[string]int `json:"seed_bests"`
+	// Score along the track of each seed's best run, see trackPace
+	SeedPaces map[string][]int `json:"seed_paces"`
+	// Fastest any run has gone
+	TopSpeed float64 `json:"top_speed"`
+}
+
+// configFile is where the file called name lives in the user's config dir.
+func configFile(name string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "subway-surfer", name), nil
+}
+
+func statsPath() (string, error) {
+	return configFile("stats.json")
+}
+
+// loadStats reads the stats file, a missing file is just empty stats.
+func loadStats() (*stats, error) {
+	st := &stats{SeedBests: map[string]int{}, SeedPaces: map[string][]int{}}
+
+	path, err := statsPath()
+	if err != nil {
+		return st, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return st, err
+	}
+	if st.SeedBests == nil {
+		st.SeedBests = map[string]int{}
+	}
+	if st.SeedPaces == nil {
+		st.SeedPaces = map[string][]int{}
+	}
+	return st, nil
+}
+
+func (st *stats) save() error {
+	path, err := statsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// seedKey is what a run on seed keeps its best under. A best only means
+// something to runs that scored the same way on the same difficulty and mode,
+// so anything off the normal preset's gets its own: the seed in decimal, then
+// the rest after slashes. ranked is false for runs that race a best but never
+// set one: learning, demos, practice, handicaps and tuned runs.
+func seedKey(cfg config, seed int64) (key string, ranked bool) {
+	key = strconv.FormatInt(seed, 10)
+	preset := difficulties[cfg.difficulty]
+	if cfg.difficulty != "normal" || cfg.mode != preset.mode || cfg.coinValue != preset.coinValue || cfg.distanceValue != defaultDistanceValue {
+		key += fmt.Sprintf("/%s/%s/%d/%g", cfg.difficulty, cfg.mode, cfg.coinValue, cfg.distanceValue)
+	}
+	return key, !cfg.learn && !cfg.demo && cfg.mode != modePractice && !cfg.handicap && !cfg.tuned
+}
+
+func (st *stats) seedBest(key string) int {
+	return st.SeedBests[key]
+}
+
+// seedPace is the pace of the best run under key, nil if it has none.
+func (st *stats) seedPace(key string) []int {
+	return st.SeedPaces[key]
+}
+
+// recordSeed keeps score and its pace as the best under key if it beats the
+// old one, and reports whether it did.
+func (st *stats) recordSeed(key string, score int, pace []int) bool {
+	if score <= st.SeedBests[key] {
+		return false
+	}
+	st.SeedBests[key] = score
+	st.SeedPaces[key] = pace
+	return true
+}
+
+// recordTopSpeed keeps speed as the fastest ever if it beats the old one, and
+// reports whether it did.
+func (st *stats) recordTopSpeed(speed float64) bool {
+	if speed <= st.TopSpeed {
+		return false
+	}
+	st.TopSpeed = speed
+	return true
+}
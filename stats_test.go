@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestSeedKey(t *testing.T) {
+	tests := []struct {
+		args   []string
+		key    string
+		ranked bool
+	}{
+		{nil, "3", true},
+		{[]string{"-difficulty", "hard"}, "3/hard/forgiving/60/10", true},
+		{[]string{"-mode", "hardcore"}, "3/normal/hardcore/50/10", true},
+		{[]string{"-coin-value", "500"}, "3/normal/forgiving/500/10", true},
+		{[]string{"-distance-value", "0"}, "3/normal/forgiving/50/0", true},
+		{[]string{"-coin-value", "50", "-distance-value", "10"}, "3", true},
+		{[]string{"-start-score", "1000"}, "3", false},
+		{[]string{"-start-speed", "12"}, "3", false},
+		{[]string{"-mode", "practice"}, "3/normal/practice/50/10", false},
+		{[]string{"-demo"}, "3", false},
+		{[]string{"-learn"}, "3", false},
+
+		// The course or how it plays moved off the preset
+		{[]string{"-course", writeCourse(t, "1 obstacle 1 low\n")}, "3", false},
+		{[]string{"-coin-lanes", "1"}, "3", false},
+		{[]string{"-coin-window", "3"}, "3", false},
+		{[]string{"-max-speed", "30"}, "3", false},
+		{[]string{"-uncapped"}, "3", false},
+		{[]string{"-speed-ramp", "0.1"}, "3", false},
+		{[]string{"-ramp", "distance"}, "3", false},
+		{[]string{"-view-distance", "30"}, "3", false},
+		{[]string{"-lookahead", "6"}, "3", false},
+		{[]string{"-rush-every", "0"}, "3", false},
+		{[]string{"-gap-every", "10"}, "3", false},
+		{[]string{"-closure-every", "15"}, "3", false},
+		{[]string{"-difficulty", "hard", "-max-speed", "16"}, "3/hard/forgiving/60/10", false},
+
+		// or set to what it already was
+		{[]string{"-max-speed", "16", "-closure-every", "40", "-coin-spacing", "1.5"}, "3", true},
+		{[]string{"-view-distance", "20", "-spawn-distance", "19"}, "3", true},
+	}
+	for _, tt := range tests {
+		cfg := testConfig(t, append([]string{"-seed", "3"}, tt.args...)...)
+		if key, ranked := seedKey(cfg, 3); key != tt.key || ranked != tt.ranked {
+			t.Errorf("%q: seedKey = %q, %v, want %q, %v", tt.args, key, ranked, tt.key, tt.ranked)
+		}
+	}
+}
+
+func TestRecordSeed(t *testing.T) {
+	st := &stats{SeedBests: map[string]int{}, SeedPaces: map[string][]int{}}
+	steps := []struct {
+		key   string
+		score int
+		kept  bool
+	}{
+		{"3", 1000, true},
+		{"3", 900, false},
+		{"3", 1000, false},
+		{"3/normal/forgiving/500/10", 5000, true},
+		{"3", 1200, true},
+	}
+	for _, s := range steps {
+		if kept := st.recordSeed(s.key, s.score, []int{s.score}); kept != s.kept {
+			t.Errorf("%d under %q: kept = %v, want %v", s.score, s.key, kept, s.kept)
+		}
+	}
+	if st.seedBest("3") != 1200 || st.seedBest("3/normal/forgiving/500/10") != 5000 {
+		t.Errorf("bests %v, the big-coin run shouldn't have touched the plain one", st.SeedBests)
+	}
+	if p := st.seedPace("3"); len(p) != 1 || p[0] != 1200 {
+		t.Errorf("pace %v, want the 1200 run's", p)
+	}
+}
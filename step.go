@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"slices"
+)
+
+// --- Stepping the sim ---
+//
+// Step is a tick of the game as a function from one state to the next: the
+// keys pressed since the last tick and how long it's been go in, the next
+// state comes out, and the state that went in is left as it was. It's what
+// FuzzStep throws random runs at. The loop doesn't use it, copying the
+// whole game every tick just to throw the old one away; it moves the one
+// game on in place with key and update, which Step is built from. The one
+// thing the two states share is the log, so a tick's events still get
+// written wherever the game's logging.
+
+// Step returns state moved on by a tick: the keys in in, in order, then dt
+// of running.
+func Step(state *game, in []keyPress, dt float64) *game {
+	g := state.clone()
+	for _, k := range in {
+		g.key(k)
+	}
+	g.update(dt)
+	return g
+}
+
+// clone is a copy of g that can be moved on without touching g, down to the
+// RNG. What's rebuilt every frame (the frame and row buffers, the HUD) is
+// shared; the screen the next frame's diffed against isn't. A game on an
+// embedder's source has no way to copy it, so the two share that.
+func (g *game) clone() *game {
+	c := *g
+	if g.src != nil {
+		c.src = g.src.clone()
+		c.rng = rand.New(c.src)
+	}
+	c.pace = slices.Clone(g.pace)
+	c.speeds = slices.Clone(g.speeds)
+	c.screen = slices.Clone(g.screen)
+	return &c
+}
+
+// clone is a copy of s that carries on from where s is without touching it.
+// math/rand doesn't let a source's state out, so it's copied whole the way a
+// value of its type would be. Replaying the draws from the seed, the way a
+// resume does, would take longer every tick the longer the run went.
+func (s *countingSource) clone() *countingSource {
+	v := reflect.ValueOf(s.src).Elem()
+	c := reflect.New(v.Type())
+	c.Elem().Set(v)
+	return &countingSource{src: c.Interface().(rand.Source64), draws: s.draws}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// fuzzKeys are the keys FuzzStep presses, picked out by the input's bytes.
+var fuzzKeys = []keyPress{0, keyLeft, keyRight, keyUp, keyDown, 'p', '?', 'x'}
+
+func TestStepLeavesStateAlone(t *testing.T) {
+	g := testGame(t, 80, 24, "-manual")
+	for range 100 {
+		g = Step(g, nil, 0.05)
+	}
+	before := g.clone()
+	a := Step(g, []keyPress{keyLeft, keyUp}, 0.05)
+	b := Step(g, []keyPress{keyLeft, keyUp}, 0.05)
+	if !reflect.DeepEqual(g, before) {
+		t.Error("Step changed the state it was given")
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Error("Step gave two different states from the same one")
+	}
+	if a.targetLane != 0 || a.jumpT <= 0 {
+		t.Errorf("keys didn't get to the next state: heading for lane %d, jump %v", a.targetLane, a.jumpT)
+	}
+
+	// Stepping on from both carries on the same course
+	for range 200 {
+		a, b = Step(a, nil, 0.05), Step(b, nil, 0.05)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Error("two copies of a state drifted apart")
+	}
+}
+
+func TestStepMatchesUpdate(t *testing.T) {
+	g, h := testGame(t, 80, 24), testGame(t, 80, 24)
+	for range 600 {
+		g = Step(g, nil, 0.05)
+		h.update(0.05)
+	}
+	if !reflect.DeepEqual(g, h) {
+		t.Error("a run stepped with Step went differently to one stepped in place")
+	}
+}
+
+func FuzzStep(f *testing.F) {
+	f.Add(int64(1), uint8(80), uint8(24), []byte{0, 1, 0, 3, 0, 0, 4, 2}, 1.0/targetFPS, false)
+	f.Add(int64(3), uint8(20), uint8(6), []byte{5, 5, 6, 7, 1, 1, 1}, 0.1, true)
+	f.Add(int64(-7), uint8(200), uint8(60), []byte{2}, -1.0, false)
+	f.Add(int64(9), uint8(1), uint8(1), []byte{}, math.NaN(), true)
+	f.Fuzz(func(t *testing.T, seed int64, w, h uint8, keys []byte, dt float64, manual bool) {
+		g := testGame(t, int(w)+1, int(h)+1, "-seed", strconv.FormatInt(seed, 10), "-manual="+strconv.FormatBool(manual), "-mode", "practice")
+		// Steps never go past -max-step in the loop, so nor do they here
+		dt = min(dt, g.cfg.maxStep)
+		for i := range 300 {
+			var in []keyPress
+			if len(keys) > 0 {
+				if k := fuzzKeys[int(keys[i%len(keys)])%len(fuzzKeys)]; k != 0 {
+					in = append(in, k)
+				}
+			}
+			g = Step(g, in, dt)
+			g.render()
+
+			if g.runnerLane < 0 || g.runnerLane >= numLanes || g.targetLane < 0 || g.targetLane >= numLanes {
+				t.Fatalf("step %d: runner in lane %d heading for %d", i, g.runnerLane, g.targetLane)
+			}
+			for _, v := range []float64{g.speed, g.laneX, g.distance, g.elapsed} {
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					t.Fatalf("step %d: speed %v, lane x %v, distance %v, elapsed %v", i, g.speed, g.laneX, g.distance, g.elapsed)
+				}
+			}
+			for _, o := range g.obstacles {
+				if o.active && (math.IsNaN(o.z) || math.IsInf(o.z, 0)) {
+					t.Fatalf("step %d: obstacle at z %v", i, o.z)
+				}
+			}
+			for _, c := range g.coinPool {
+				if c.active && (math.IsNaN(c.z) || math.IsInf(c.z, 0)) {
+					t.Fatalf("step %d: coin at z %v", i, c.z)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// --- Terminal I/O ---
+
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+	sizeFailLimit = targetFPS // size queries failing in a row before resizes are ignored
+
+	resizeSettleTicks = 3 // ticks a new size has to hold before the screen's redone for it
+)
+
+// terminal is where a game reads keys from and draws frames to. The fds are
+// only used for raw mode and size queries; set them to -1 when in or out
+// aren't backed by a file (an in-memory buffer, say) and the game falls back
+// to plain reads/writes at the default size.
+type terminal struct {
+	in    io.Reader
+	inFd  int
+	out   io.Writer
+	outFd int
+}
+
+// stdTerminal is the process's own stdin and stdout.
+func stdTerminal() terminal {
+	return terminal{
+		in:    os.Stdin,
+		inFd:  int(os.Stdin.Fd()),
+		out:   os.Stdout,
+		outFd: int(os.Stdout.Fd()),
+	}
+}
+
+// openTerminal is stdTerminal with frames going to the named output instead:
+// "stdout", "stderr", or a path such as another terminal's tty. Sizes are
+// queried from whichever one it is. The close func releases anything opened.
+func openTerminal(out string) (terminal, func() error, error) {
+	t := stdTerminal()
+	switch out {
+	case "", "stdout":
+		return t, func() error { return nil }, nil
+	case "stderr":
+		t.out, t.outFd = os.Stderr, int(os.Stderr.Fd())
+		return t, func() error { return nil }, nil
+	}
+
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return t, nil, err
+	}
+	t.out, t.outFd = f, int(f.Fd())
+	return t, f.Close, nil
+}
+
+// size reports the output's size. When it can't be queried it falls back to
+// $COLUMNS and $LINES, then the default size, still returning the error.
+func (t terminal) size() (w, h int, err error) {
+	if t.outFd < 0 {
+		return defaultWidth, defaultHeight, nil
+	}
+	w, h, err = term.GetSize(t.outFd)
+	if err != nil {
+		return envDim("COLUMNS", defaultWidth), envDim("LINES", defaultHeight), err
+	}
+	return w, h, nil
+}
+
+// envDim reads a screen dimension from the environment, or def if it's unset
+// or not a positive number.
+func envDim(name string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// sizeWatch follows the terminal's size from tick to tick. A failed query
+// keeps the last size, and if it keeps failing the output has probably
+// stopped being a terminal, so it stops asking. Dragging a window corner
+// changes the size every tick, so a new size only takes once it's held still
+// for a bit, instead of clearing every tick.
+type sizeWatch struct {
+	off          bool // given up asking
+	fails        int  // queries failed in a row
+	pendW, pendH int  // size seen last tick
+	settled      int  // ticks pendW x pendH has held
+	w, h         int  // size last taken, which the game can be bigger than with -too-small clamp
+}
+
+func newSizeWatch(w, h int) *sizeWatch {
+	return &sizeWatch{pendW: w, pendH: h, w: w, h: h}
+}
+
+// check queries size once. It reports whether w and h have just changed, and
+// the query's error if it's the one that made it give up.
+func (s *sizeWatch) check(size func() (int, int, error)) (resized bool, err error) {
+	if s.off {
+		return false, nil
+	}
+	nw, nh, err := size()
+	if err != nil {
+		s.fails++
+		if s.fails >= sizeFailLimit {
+			s.off = true
+			return false, err
+		}
+		return false, nil
+	}
+	s.fails = 0
+	switch {
+	case nw != s.pendW || nh != s.pendH:
+		s.pendW, s.pendH, s.settled = nw, nh, 1
+	case nw == s.w && nh == s.h:
+	case s.settled+1 < resizeSettleTicks:
+		s.settled++
+	default:
+		s.w, s.h = nw, nh
+		return true, nil
+	}
+	return false, nil
+}
+
+func (t terminal) write(s string) {
+	io.WriteString(t.out, s)
+}
+
+// restorer puts the terminal back the way play found it: out of raw mode,
+// off the alt screen (or below the inline game), cursor showing and autowrap
+// on. What's been changed is noted as it's done, and restoreTerminal undoes
+// just that, once, however many times it's called and from wherever: the
+// teardown on the way out, a second quit signal, a panic on any goroutine.
+//
+// Frames go out through it too, so one can't land halfway through the
+// teardown from another goroutine, or after it's done. And anything that has
+// to be finished off before the process goes (a log to flush, a cast to
+// close) is noted with onExit, for a second signal to do before it exits.
+type restorer struct {
+	mu      sync.Mutex
+	out     io.Writer // where frames go, a cast may be swapped in later
+	inFd    int
+	done    bool
+	raw     *term.State    // how input was before raw mode, nil if it never went raw
+	leave   func() string  // what takes the game's screen down, nil until it's up
+	closers []func() error // noted with onExit, oldest first
+}
+
+// Write sends p to the terminal, unless it's already been put back, when p's
+// dropped.
+func (r *restorer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return len(p), nil
+	}
+	return r.out.Write(p)
+}
+
+// writeThrough sends everything from here on through w, which passes it on
+// to where it was going.
+func (r *restorer) writeThrough(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out = w
+}
+
+// rawMode notes that input went raw, and how it was before.
+func (r *restorer) rawMode(old *term.State) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.raw = old
+}
+
+// screenUp notes that the game's screen is up, and how to take it down.
+func (r *restorer) screenUp(leave func() string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.leave = leave
+}
+
+// onExit notes that close has to be done before the process goes, and
+// returns it to be called the usual way instead. Whichever of the two gets
+// there first does it, once.
+func (r *restorer) onExit(close func() error) func() error {
+	var once sync.Once
+	var err error
+	closeOnce := func() error {
+		once.Do(func() { err = close() })
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closers = append(r.closers, closeOnce)
+	return closeOnce
+}
+
+// restoreTerminal undoes whatever's been noted, the first time it's called.
+func (r *restorer) restoreTerminal() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.restore()
+}
+
+// restore is restoreTerminal with r.mu held.
+func (r *restorer) restore() {
+	if r.done {
+		return
+	}
+	r.done = true
+	if r.leave != nil {
+		io.WriteString(r.out, "\033[?7h")  // autowrap back on
+		io.WriteString(r.out, "\033[?25h") // show cursor
+		io.WriteString(r.out, r.leave())
+	}
+	if r.raw != nil {
+		term.Restore(r.inFd, r.raw)
+	}
+}
+
+// shutDown is the whole way out, for when the process is going without play
+// returning: the terminal put back, then everything noted with onExit done,
+// newest first. Errors go to stderr, there's nobody else left to tell.
+func (r *restorer) shutDown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.restore()
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if err := r.closers[i](); err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't finish up: %v\n", err)
+		}
+	}
+}
+
+// restoreOnPanic is deferred by goroutines play starts. A panic anywhere but
+// play's own goroutine skips play's deferred teardown, so this puts the
+// terminal back before the panic carries on and takes the process down.
+func (r *restorer) restoreOnPanic() {
+	if p := recover(); p != nil {
+		r.restoreTerminal()
+		panic(p)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSizeWatch(t *testing.T) {
+	errNoTTY := errors.New("not a terminal")
+	type query struct {
+		w, h int
+		err  error
+	}
+	ok := func(w, h int) query { return query{w, h, nil} }
+	failed := query{err: errNoTTY}
+
+	tests := []struct {
+		name    string
+		queries []query
+		resized []bool // after each query
+		w, h    int    // size taken at the end
+		off     bool
+	}{
+		{"same size", []query{ok(80, 24), ok(80, 24)}, []bool{false, false}, 80, 24, false},
+		{"new size settles", []query{ok(100, 30), ok(100, 30), ok(100, 30), ok(100, 30)}, []bool{false, false, true, false}, 100, 30, false},
+		{"dragging never settles", []query{ok(90, 24), ok(91, 24), ok(92, 24), ok(93, 24)}, []bool{false, false, false, false}, 80, 24, false},
+		{"dragged back before settling", []query{ok(100, 30), ok(80, 24), ok(80, 24), ok(80, 24)}, []bool{false, false, false, false}, 80, 24, false},
+		{"a failure keeps the last size", []query{failed, ok(80, 24)}, []bool{false, false}, 80, 24, false},
+		{"a failure doesn't reset settling", []query{ok(100, 30), failed, ok(100, 30), ok(100, 30)}, []bool{false, false, false, true}, 100, 30, false},
+	}
+	for _, tt := range tests {
+		s := newSizeWatch(80, 24)
+		for i, q := range tt.queries {
+			resized, err := s.check(func() (int, int, error) { return q.w, q.h, q.err })
+			if err != nil {
+				t.Errorf("%s: query %d gave up: %v", tt.name, i, err)
+			}
+			if resized != tt.resized[i] {
+				t.Errorf("%s: query %d resized = %v, want %v", tt.name, i, resized, tt.resized[i])
+			}
+		}
+		if s.w != tt.w || s.h != tt.h || s.off != tt.off {
+			t.Errorf("%s: ended at %dx%d off %v, want %dx%d off %v", tt.name, s.w, s.h, s.off, tt.w, tt.h, tt.off)
+		}
+	}
+}
+
+func TestSizeWatchGivesUp(t *testing.T) {
+	s := newSizeWatch(80, 24)
+	calls := 0
+	failing := func() (int, int, error) {
+		calls++
+		return 0, 0, errors.New("not a terminal")
+	}
+	for i := 1; i < sizeFailLimit; i++ {
+		if _, err := s.check(failing); err != nil || s.off {
+			t.Fatalf("gave up after %d failures, want %d", i, sizeFailLimit)
+		}
+	}
+	if _, err := s.check(failing); err == nil || !s.off {
+		t.Fatalf("still asking after %d failures", sizeFailLimit)
+	}
+
+	// Once it's given up, the size is left alone and not asked again
+	if resized, err := s.check(failing); resized || err != nil {
+		t.Errorf("after giving up: resized %v, err %v", resized, err)
+	}
+	if calls != sizeFailLimit {
+		t.Errorf("size asked %d times, want %d", calls, sizeFailLimit)
+	}
+	if s.w != 80 || s.h != 24 {
+		t.Errorf("size after giving up %dx%d, want the last one, 80x24", s.w, s.h)
+	}
+
+	// A success in between starts the count over
+	s = newSizeWatch(80, 24)
+	for range sizeFailLimit - 1 {
+		s.check(failing)
+	}
+	s.check(func() (int, int, error) { return 80, 24, nil })
+	for range sizeFailLimit - 1 {
+		s.check(failing)
+	}
+	if s.off {
+		t.Error("gave up though the failures weren't in a row")
+	}
+}
+
+func TestRestorer(t *testing.T) {
+	var out bytes.Buffer
+	r := &restorer{out: &out, inFd: -1}
+	r.Write([]byte("before the screen's up "))
+	r.restoreTerminal()
+	r.restoreTerminal()
+	if got := out.String(); got != "before the screen's up " {
+		t.Errorf("put back a screen that never went up: %q", got)
+	}
+
+	out.Reset()
+	r = &restorer{out: &out, inFd: -1}
+	r.screenUp(func() string { return "<leave>" })
+	r.Write([]byte("frame "))
+	r.restoreTerminal()
+	r.Write([]byte("late frame"))
+	r.restoreTerminal()
+	if got, want := out.String(), "frame \033[?7h\033[?25h<leave>"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
+
+func TestRestorerShutDown(t *testing.T) {
+	var out bytes.Buffer
+	r := &restorer{out: &out, inFd: -1}
+	r.screenUp(func() string { return "<leave>" })
+
+	var closed []string
+	closer := func(name string) func() error {
+		return func() error {
+			closed = append(closed, name)
+			return nil
+		}
+	}
+	closeLog := r.onExit(closer("log"))
+	r.onExit(closer("cast"))
+	closeLog() // already done the usual way, so not again
+	closed = closed[:0]
+
+	r.shutDown()
+	closeLog()
+	if want := []string{"cast"}; !slices.Equal(closed, want) {
+		t.Errorf("closed %q, want %q", closed, want)
+	}
+	if got := out.String(); !strings.HasSuffix(got, "<leave>") {
+		t.Errorf("terminal not put back: %q", got)
+	}
+}
+
+func TestRestorerNoFramesAfter(t *testing.T) {
+	// Frames coming from the loop while a signal shuts down from another
+	// goroutine never go out after the terminal's been put back
+	for range 20 {
+		var out bytes.Buffer
+		r := &restorer{out: &out, inFd: -1}
+		r.screenUp(func() string { return "<leave>" })
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				r.Write([]byte("frame"))
+			}
+		}()
+		r.shutDown()
+		wg.Wait()
+		if got := out.String(); !strings.HasSuffix(got, "<leave>") {
+			t.Fatalf("a frame came after the terminal was put back: %q", got)
+		}
+	}
+}
+
+func TestRestoreOnPanic(t *testing.T) {
+	var out bytes.Buffer
+	r := &restorer{out: &out, inFd: -1}
+	r.screenUp(func() string { return "<leave>" })
+	defer func() {
+		if p := recover(); p != "key reader" {
+			t.Errorf("recovered %v, want the panic carried on", p)
+		}
+		if got := out.String(); got != "\033[?7h\033[?25h<leave>" {
+			t.Errorf("wrote %q before panicking", got)
+		}
+	}()
+	func() {
+		defer r.restoreOnPanic()
+		panic("key reader")
+	}()
+}
@@ -0,0 +1,153 @@
+package main
+
+// --- Top-down view ---
+//
+// A flat alternative to the perspective view: lanes are columns of equal
+// width, the runner sits near the bottom and everything scrolls straight down
+// toward them. It draws the same simulation, only the projection differs.
+
+// Views
+const (
+	viewPerspective = "perspective"
+	viewTopDown     = "topdown"
+)
+
+// topDownRunnerRow is the row the runner's feet are on.
+func (g *game) topDownRunnerRow() int {
+	return g.height - 2
+}
+
+// topDownRow is the screen row an object at z sits on, the runner's row at
+// runnerZ up to the top of the screen at farZ.
+func (g *game) topDownRow(z float64) int {
+	runnerRow := g.topDownRunnerRow()
+	return runnerRow - int((z-runnerZ)/(g.cfg.farZ-runnerZ)*float64(runnerRow))
+}
+
+// topDownLaneX is the left column of the given (possibly fractional) lane.
+// Every lane is the same width, with a divider column after it.
+func (g *game) topDownLaneX(lane float64) int {
+	tw := g.trackCols()
+	left := (g.width - tw) / 2
+	lw := (tw - 2) / numLanes
+	return left + 1 + int(lane*float64(lw))
+}
+
+func (g *game) drawTopDown(buf []byte, row int) {
+	tw := g.trackCols()
+	left := (g.width - tw) / 2
+	right := g.topDownLaneX(numLanes) - 1
+	lw := (tw - 2) / numLanes
+	scroll := int(g.scrollOff * 2)
+
+	// Ground texture outside track, scrolling with the track
+	g.fillGround(buf, row-scroll)
+
+	// Track surface and rails
+	for x := left; x <= right && x < len(buf); x++ {
+		if x >= 0 {
+			buf[x] = ' '
+		}
+	}
+	placeString(buf, left, "|")
+	placeString(buf, right, "|")
+
+	// Lane dividers, dollar signs inside a bonus zone
+	divider := ":"
+	if g.zone.active && row >= g.topDownRow(g.zone.end) && row <= g.topDownRow(g.zone.start) {
+		divider = "$"
+	}
+	if (row-scroll)%3 != 0 {
+		for l := 1; l < numLanes; l++ {
+			placeString(buf, g.topDownLaneX(float64(l))-1, divider)
+		}
+	}
+
+	// Bonus zone start and end lines
+	if g.zone.active && ((g.zone.start >= 0 && row == g.topDownRow(g.zone.start)) || (g.zone.end <= g.cfg.farZ && row == g.topDownRow(g.zone.end))) {
+		for x := left + 1; x < right; x++ {
+			placeString(buf, x, "=")
+		}
+	}
+
+	// Fire filling a closed lane
+	if c := g.closure; c.active && row >= g.topDownRow(min(c.end, g.cfg.farZ)) && row <= g.topDownRow(max(c.start, 0)) {
+		x := g.topDownLaneX(float64(c.lane))
+		for col := 1; col < lw-1; col++ {
+			placeStringBytes(buf, x+col, []byte{g.fireGlyph(x+col, row)})
+		}
+	}
+
+	// No ground across a gap, rails included
+	if p := g.gap; p.active && row >= g.topDownRow(min(p.end, g.cfg.farZ)) && row <= g.topDownRow(max(p.start, 0)) {
+		for x := left; x <= right; x++ {
+			placeString(buf, x, " ")
+		}
+	}
+
+	// Checkered finish line coming up in level mode
+	if g.cfg.levelLength > 0 {
+		finishZ := g.cfg.levelLength - g.distance
+		if finishZ >= 0 && finishZ <= g.cfg.farZ {
+			if r := g.topDownRow(finishZ); row == r || row == r-1 {
+				for x := left + 1; x < right; x++ {
+					if (x+row)%2 == 0 {
+						placeString(buf, x, "#")
+					}
+				}
+			}
+		}
+	}
+
+	// Obstacles fill most of their lane
+	for i := range g.obstacles {
+		obs := &g.obstacles[i]
+		if !obs.active || obs.z > g.cfg.farZ {
+			continue
+		}
+		r := g.topDownRow(obs.z)
+		glyph := "#"
+		switch obs.kind {
+		case kindLow:
+			glyph = "^"
+		case kindHigh:
+			glyph = "="
+		}
+		// Only barriers are two rows deep
+		if row == r || (row == r-1 && obs.kind == kindBarrier) {
+			x := g.topDownLaneX(float64(obs.lane))
+			hollow := obs.kind == kindBarrier && !g.threatens(obs)
+			for c := 1; c < lw-1; c++ {
+				if !hollow || c == 1 || c == lw-2 {
+					placeString(buf, x+c, glyph)
+				}
+			}
+		}
+	}
+
+	// Coins sit in the middle of their lane, or slide over to the runner
+	for i := range g.coinPool {
+		cn := &g.coinPool[i]
+		if cn.active && cn.z <= g.cfg.farZ && row == g.topDownRow(cn.z) {
+			placeStringBytes(buf, g.topDownLaneX(g.snap(cn.x))+lw/2, []byte{g.coinGlyph(i)})
+		}
+	}
+
+	g.drawTimings(buf, row, 0)
+
+	// Runner, seen from above: bigger in the air, stretched out sliding
+	rx := g.topDownLaneX(g.snap(g.laneX)) + lw/2
+	head, body := "O", "/|\\"
+	switch {
+	case g.airborne():
+		head, body = "(O)", "/ | \\"
+	case g.sliding():
+		head, body = "O", "_|_"
+	}
+	switch row {
+	case g.topDownRunnerRow() - 1:
+		placeString(buf, rx-len(head)/2, head)
+	case g.topDownRunnerRow():
+		placeString(buf, rx-len(body)/2, body)
+	}
+}
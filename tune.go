@@ -0,0 +1,73 @@
+//go:build tune
+
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// --- Live tuning ---
+//
+// Only built with -tags tune. -tune shows a panel of balance knobs in the
+// bottom-left corner, [ and ] pick one and - and + turn it, all while the run
+// carries on, so the feel of a change shows straight away.
+
+type tunable struct {
+	name     string
+	step     float64
+	min, max float64
+	value    func(*config) *float64
+	limit    func(*config) float64 // a lower max the run's config sets, nil for none
+}
+
+var tunables = []tunable{
+	{"speed ramp", 0.01, 0, 1, func(c *config) *float64 { return &c.speedRamp }, nil},
+	{"spawn scale", 0.1, 0.1, 5, func(c *config) *float64 { return &c.spawnScale }, nil},
+	{"lookahead", 0.5, runnerZ, maxViewDistance, func(c *config) *float64 { return &c.lookahead }, func(c *config) float64 { return c.spawnZ }},
+	{"jump secs", 0.05, minJumpSecs, maxJumpSecs, func(c *config) *float64 { return &c.jumpSecs }, nil},
+}
+
+func tuneFlags(fs *flag.FlagSet, cfg *config) {
+	fs.BoolVar(&cfg.tune, "tune", false, "show the live tuning panel: [ and ] pick a knob, - and + turn it")
+}
+
+// tuneKey handles the tuning panel's keys, reporting whether k was one.
+func (g *game) tuneKey(k keyPress) bool {
+	t := tunables[g.tuneSel]
+	v := t.value(&g.cfg)
+	switch k {
+	case '[':
+		g.tuneSel = (g.tuneSel + len(tunables) - 1) % len(tunables)
+	case ']':
+		g.tuneSel = (g.tuneSel + 1) % len(tunables)
+	case '-', '_':
+		*v = max(*v-t.step, t.min)
+	case '+', '=':
+		hi := t.max
+		if t.limit != nil {
+			hi = min(hi, t.limit(&g.cfg))
+		}
+		*v = min(*v+t.step, hi)
+	default:
+		return false
+	}
+	g.log.Info("tune", "t", g.elapsed, "name", t.name, "value", *t.value(&g.cfg))
+	return true
+}
+
+// tuneLines is the tuning panel, with the knob being turned marked.
+func (g *game) tuneLines() []string {
+	if !g.cfg.tune {
+		return nil
+	}
+	lines := []string{" TUNE  [ ] pick  - + turn "}
+	for i, t := range tunables {
+		mark := ' '
+		if i == g.tuneSel {
+			mark = '>'
+		}
+		lines = append(lines, fmt.Sprintf(" %c %-11s %6.2f ", mark, t.name, *t.value(&g.cfg)))
+	}
+	return lines
+}
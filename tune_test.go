@@ -0,0 +1,28 @@
+//go:build tune
+
+package main
+
+import "testing"
+
+func TestTuneLookaheadStopsAtSpawn(t *testing.T) {
+	tests := []struct {
+		args []string
+		want float64
+	}{
+		{nil, defaultFarZ - spawnInset},
+		{[]string{"-view-distance", "40"}, 39},
+		{[]string{"-view-distance", "40", "-spawn-distance", "12"}, 12},
+	}
+	for _, tt := range tests {
+		g := testGame(t, 80, 24, append([]string{"-tune"}, tt.args...)...)
+		for tunables[g.tuneSel].name != "lookahead" {
+			g.key(']')
+		}
+		for range 200 {
+			g.key('+')
+		}
+		if g.cfg.lookahead != tt.want {
+			t.Errorf("%q: lookahead turned up to %v, want %v", tt.args, g.cfg.lookahead, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// --- Build info ---
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%F)"
+//
+// Anything left unset falls back to what the go tool stamped into the binary.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo fills in whatever the ldflags didn't from the binary's own build
+// info, so plain go build and go install still say something useful.
+func buildInfo() (ver, rev, built string) {
+	ver, rev, built = version, commit, date
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if ver == "" && bi.Main.Version != "" {
+			ver = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && rev == "":
+				rev = s.Value
+				if len(rev) > 12 {
+					rev = rev[:12]
+				}
+			case s.Key == "vcs.time" && built == "":
+				built = s.Value
+			}
+		}
+	}
+	if ver == "" {
+		ver = "(devel)"
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	return ver, rev, built
+}
+
+func printVersion(out io.Writer) {
+	ver, rev, built := buildInfo()
+	fmt.Fprintf(out, "subway-surfer %s (commit %s, built %s)\n", ver, rev, built)
+}
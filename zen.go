@@ -0,0 +1,26 @@
+package main
+
+// --- Zen mode ---
+//
+// -zen is for running for the sake of it. There's no HUD and no coin rush
+// banner, a crash doesn't shake the screen, and everything's drawn faint, the
+// half-block track in cooler colours. It plays as practice unless -mode says
+// otherwise, so the run goes on until you quit. Only what's drawn changes:
+// the course and the score underneath are the same as any other run.
+
+// zenText is the SGR zen draws text in, faint.
+const zenText = "\033[0;2m"
+
+// plainText is the SGR text's drawn in otherwise.
+const plainText = "\033[0m"
+
+// zenPxSGR is pxSGR in zen's colours: blues and greens, with no red in it.
+var zenPxSGR = [...]int{pxRail: 90, pxTie: 90, pxBarrier: 34, pxLow: 32, pxHigh: 36, pxCoin: 33, pxFire: 35}
+
+// palette is the pixel colours and text SGR rows are drawn with.
+func (g *game) palette() (px []int, text string) {
+	if g.cfg.zen {
+		return zenPxSGR[:], zenText
+	}
+	return pxSGR[:], plainText
+}
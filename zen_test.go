@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZen(t *testing.T) {
+	for _, view := range [][]string{{}, {"-view", "topdown"}, {"-halfblock"}} {
+		g := testGame(t, 80, 24, append([]string{"-zen", "-debug-render"}, view...)...)
+		for range 200 {
+			g.update(0.05)
+		}
+		if g.cfg.mode != modePractice {
+			t.Errorf("%v: zen played as %s", view, g.cfg.mode)
+		}
+
+		// Every row goes out faint, with no HUD on any of them
+		g.redraw = true
+		frame := string(g.render())
+		rows := strings.Split(frame, ";1H")[1:]
+		if len(rows) != g.height {
+			t.Fatalf("%v: %d rows drawn", view, len(rows))
+		}
+		for i, row := range rows {
+			if !strings.HasPrefix(row, zenText) {
+				t.Errorf("%v: row %d isn't faint: %q", view, i, row[:min(len(row), 20)])
+			}
+		}
+		for _, hud := range []string{"SCORE", "COINS", "SPEED"} {
+			if strings.Contains(frame, hud) {
+				t.Errorf("%v: %s on the screen", view, hud)
+			}
+		}
+
+		// A crash doesn't shake it
+		g.crash()
+		if g.shake != 0 {
+			t.Errorf("%v: a crash shook the screen", view)
+		}
+	}
+
+	// Asking for a mode still gets it
+	if g := testGame(t, 80, 24, "-zen", "-mode", "hardcore"); g.cfg.mode != modeHardcore {
+		t.Errorf("-zen -mode hardcore played as %s", g.cfg.mode)
+	}
+}
+
+func TestZenPalette(t *testing.T) {
+	// No red anywhere in the half-block track
+	for px, sgr := range zenPxSGR {
+		if sgr == 31 || sgr == 91 {
+			t.Errorf("pixel %d is red", px)
+		}
+	}
+}